@@ -0,0 +1,227 @@
+package graph
+
+import (
+	"context"
+	it "immutable_tree"
+	"math"
+	sheap "slice_heap"
+)
+
+// MeetingTraverseState is implemented by private states that limit where a
+// path searched forward from its start can join one searched backward from
+// its end, e.g., only where the range left on arrival covers the distance
+// still to go before the next landing. States that do not implement it can
+// join anywhere.
+type MeetingTraverseState[S any] interface {
+	Meets(backward S) bool
+}
+
+// frontier is the search from one end of a bidirectional traversal. States
+// searched backward are handed each vertex the way the graph holds it, so
+// its From is the node being moved to.
+type frontier[R NodeRecord, S TraverseState[R, S]] struct {
+	incoming   [][]*Vertex[R] // only when searching backward
+	dominating bool
+	sh         *sheap.SliceHeap[*PublicTraverseState[R, S]]
+	expanded   [][]*PublicTraverseState[R, S] // by node id
+	kept       keptStates[R, S]               // only with dominance
+	sequence   int
+	observer   TraverseObserver[R]
+}
+
+func newFrontier[R NodeRecord, S TraverseState[R, S]](nodeCount int, privateState S, start *Node[R], incoming [][]*Vertex[R], observer TraverseObserver[R]) *frontier[R, S] {
+	f := &frontier[R, S]{incoming: incoming, observer: observer,
+		sh:       sheap.NewSliceHeap(PublicStateLessThan[R, S]),
+		expanded: make([][]*PublicTraverseState[R, S], nodeCount)}
+
+	var before *it.Tree
+	if _, f.dominating = any(privateState).(DominatingTraverseState[S]); f.dominating {
+		before = it.NewTree()
+		f.kept = make(keptStates[R, S], nodeCount)
+	}
+	state := &PublicTraverseState[R, S]{0.0, f.sequence, start, &VisitedList[R]{start, nil}, before, privateState, false}
+	if f.dominating {
+		f.kept.keep(state)
+	}
+	f.sh.PushItem(state)
+	return f
+}
+
+func (f *frontier[R, S]) isBackward() bool {
+	return f.incoming != nil
+}
+
+// leastCost returns the cost of the cheapest state still to be expanded, or
+// 0 once there are none, since paths not yet found can still join states
+// already expanded.
+func (f *frontier[R, S]) leastCost() float64 {
+	if f.sh.IsEmpty() {
+		return 0.0
+	}
+	return f.sh.Peek().totalCost
+}
+
+// pop returns the next state to expand, or nil if it is dominated by one
+// pushed since or, for states without dominance, follows one already
+// expanded.
+func (f *frontier[R, S]) pop() *PublicTraverseState[R, S] {
+	state := f.sh.PopItem()
+	if state.dead || (!f.dominating && len(f.expanded[state.node.id]) > 0) {
+		f.observer.Popped(state.node, state.totalCost, false)
+		return nil
+	}
+	f.expanded[state.node.id] = append(f.expanded[state.node.id], state)
+	f.observer.Popped(state.node, state.totalCost, true)
+	return state
+}
+
+// expand pushes the states that follow one just popped, returning them so
+// that they can be joined with the other frontier.
+func (f *frontier[R, S]) expand(state *PublicTraverseState[R, S]) (pushed []*PublicTraverseState[R, S]) {
+	var onPath *it.Tree
+	if state.before != nil {
+		onPath = state.before.AddValue(state.node)
+	}
+
+	degree := state.node.degree()
+	if f.isBackward() {
+		degree = len(f.incoming[state.node.id])
+	}
+	for i := 0; i < degree; i++ {
+		var vertex *Vertex[R]
+		var nextNode *Node[R]
+		if f.isBackward() {
+			vertex = f.incoming[state.node.id][i]
+			nextNode = vertex.From
+		} else {
+			vertex = state.node.vertex(i)
+			nextNode = vertex.To
+		}
+
+		// only nodes already expanded can be on the path
+		if expanded := f.expanded[nextNode.id]; len(expanded) > 0 && (!f.dominating || onPath.HasValue(nextNode)) {
+			f.observer.Rejected(vertex, REJECT_VISITED)
+			continue
+		}
+		nextPrivateState, ok := state.privateState.TraverseStateHelper(vertex)
+		if !ok {
+			f.observer.Rejected(vertex, REJECT_STATE)
+			continue
+		}
+		totalCost := state.totalCost + vertex.CostFor(state.privateState)
+		if f.dominating && f.kept.dominated(nextNode, totalCost, nextPrivateState) {
+			f.observer.Rejected(vertex, REJECT_DOMINATED)
+			continue
+		}
+		f.sequence++
+		next := &PublicTraverseState[R, S]{totalCost, f.sequence, nextNode, &VisitedList[R]{nextNode, state.visited}, onPath, nextPrivateState, false}
+		if f.dominating {
+			f.kept.keep(next)
+		}
+		f.sh.PushItem(next)
+		f.observer.Pushed(vertex, next.totalCost, f.sh.Len())
+		pushed = append(pushed, next)
+	}
+	return
+}
+
+// join returns the path made of a forward state and a backward state at the
+// same node, or nil if they cannot be joined or share any other node.
+func join[R NodeRecord, S TraverseState[R, S]](forward, backward *PublicTraverseState[R, S]) []*Node[R] {
+	if meeting, ok := any(forward.privateState).(MeetingTraverseState[S]); ok && !meeting.Meets(backward.privateState) {
+		return nil
+	}
+
+	path := forward.visited.MakeSlice()
+	onPath := make(map[*Node[R]]bool, len(path))
+	for _, n := range path {
+		onPath[n] = true
+	}
+	for l := backward.visited.next; l != nil; l = l.next {
+		if onPath[l.node] {
+			return nil
+		}
+		path = append(path, l.node)
+	}
+	return path
+}
+
+// TraverseBidirectional finds the same cheapest path as Traverse, searching
+// forward from one end and backward from the other until the two searches
+// meet. The backward search starts from its own private state, which mirrors
+// the forward one: it is handed vertices against their direction and says
+// what each allows of the path before it rather than after. Where both
+// states implement MeetingTraverseState the forward state decides whether
+// they join.
+//
+// Searching backward needs the vertices arriving at each node, which a
+// frozen graph keeps; otherwise they are gathered for each call. The steps of
+// both searches are told to the forward state's observer, if it has one.
+func (g *Graph[R, S]) TraverseBidirectional(forwardState, backwardState S, from, to *Node[R]) (path []*Node[R], totalCost float64, ok bool) {
+	path, totalCost, ok, _ = g.TraverseBidirectionalContext(context.Background(), forwardState, backwardState, from, to, 0)
+	return
+}
+
+// TraverseBidirectionalContext is TraverseBidirectional giving up like
+// TraverseContext. Having given up, it still returns the cheapest path
+// found, if any, which may not be the cheapest there is.
+func (g *Graph[R, S]) TraverseBidirectionalContext(ctx context.Context, forwardState, backwardState S, from, to *Node[R], maxStates int) (path []*Node[R], totalCost float64, ok bool, err error) {
+	incoming := g.incoming
+	if !g.IsFrozen() {
+		incoming = g.arrivals()
+	}
+
+	observer := observerFor[R](forwardState)
+	observer.Started([]*Node[R]{from}, []*Node[R]{to})
+	defer func() { observer.Finished(ok) }()
+
+	forward := newFrontier(len(g.nodes), forwardState, from, nil, observer)
+	backward := newFrontier(len(g.nodes), backwardState, to, incoming, observer)
+	totalCost = math.Inf(1)
+	b := &budget{ctx, maxStates, 2}
+
+	// Any path not yet found joins a state still to be expanded on at least
+	// one side, so once the cheapest left on each side cost as much as the
+	// best path found together, it cannot be beaten.
+	for !forward.sh.IsEmpty() || !backward.sh.IsEmpty() {
+		if forward.leastCost()+backward.leastCost() >= totalCost {
+			break
+		}
+		if err = b.spent(forward.leastCost() + backward.leastCost()); err != nil {
+			break
+		}
+
+		this, other := forward, backward
+		if forward.sh.IsEmpty() || (!backward.sh.IsEmpty() && backward.leastCost() < forward.leastCost()) {
+			this, other = backward, forward
+		}
+
+		state := this.pop()
+		if state == nil {
+			continue
+		}
+
+		pushed := this.expand(state)
+		b.states += len(pushed)
+		for _, s := range append([]*PublicTraverseState[R, S]{state}, pushed...) {
+			for _, o := range other.expanded[s.node.id] {
+				if s.totalCost+o.totalCost >= totalCost {
+					continue
+				}
+				f, b := s, o
+				if this.isBackward() {
+					f, b = o, s
+				}
+				if joined := join(f, b); joined != nil {
+					path, totalCost = joined, s.totalCost+o.totalCost
+				}
+			}
+		}
+	}
+
+	if path == nil {
+		return nil, 0.0, false, err
+	}
+	observer.Reached(to, totalCost)
+	return path, totalCost, true, err
+}
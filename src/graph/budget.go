@@ -0,0 +1,60 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrCanceled       = errors.New("traversal canceled")
+	ErrBudgetExceeded = errors.New("traversal exceeded its budget of states")
+)
+
+// A TraverseError is returned by a traversal that gave up before finishing,
+// with what it had learnt by then. It matches ErrCanceled or
+// ErrBudgetExceeded with errors.Is, and when canceled, the context's error
+// too.
+type TraverseError struct {
+	Err        error   // ErrCanceled or ErrBudgetExceeded
+	Cause      error   // the context's error, if canceled
+	LowerBound float64 // no path costs less
+	States     int     // states pushed so far
+}
+
+func (e *TraverseError) Error() string {
+	reason := e.Err.Error()
+	if e.Cause != nil {
+		reason += " (" + e.Cause.Error() + ")"
+	}
+	return fmt.Sprintf("%s after %d states, with no path costing less than %f", reason, e.States, e.LowerBound)
+}
+
+func (e *TraverseError) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.Cause}
+}
+
+// budget keeps a traversal within its context and its limit on the number
+// of states pushed, 0 for none.
+type budget struct {
+	ctx       context.Context
+	maxStates int
+	states    int
+}
+
+// spent returns the error to give up with, if the traversal must, given the
+// least that any path not yet found could cost.
+func (b *budget) spent(lowerBound float64) error {
+	if b.maxStates > 0 && b.states > b.maxStates {
+		return &TraverseError{ErrBudgetExceeded, nil, lowerBound, b.states}
+	}
+	select {
+	case <-b.ctx.Done():
+		return &TraverseError{ErrCanceled, b.ctx.Err(), lowerBound, b.states}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,61 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestTraverseBudget(t *testing.T) {
+	g, nodes := randomGraph[anyPath](rand.New(rand.NewSource(10)), 200)
+	r := rand.New(rand.NewSource(11))
+	for trial := 0; trial < 20; trial++ {
+		from, to := nodes[r.Intn(len(nodes))], nodes[r.Intn(len(nodes))]
+		_, expected, _ := g.Traverse(anyPath{}, from, to)
+
+		_, cost, ok, err := g.TraverseContext(context.Background(), anyPath{}, from, to, 1000000)
+		if err != nil || !ok || cost != expected {
+			t.Errorf("%s to %s costs %f (%v) within budget but %f without", from.Record, to.Record, cost, err, expected)
+		}
+		_, cost, ok, err = g.TraverseBidirectionalContext(context.Background(), anyPath{}, anyPath{}, from, to, 1000000)
+		if err != nil || !ok || cost != expected {
+			t.Errorf("%s to %s costs %f (%v) from both ends within budget but %f without", from.Record, to.Record, cost, err, expected)
+		}
+
+		if from == to {
+			continue
+		}
+		var te *TraverseError
+		_, _, ok, err = g.TraverseContext(context.Background(), anyPath{}, from, to, 3)
+		if ok || !errors.Is(err, ErrBudgetExceeded) || !errors.As(err, &te) {
+			t.Fatalf("%s to %s over budget gives %t, %v", from.Record, to.Record, ok, err)
+		}
+		if te.LowerBound > expected || te.States <= 3 {
+			t.Errorf("%s to %s costs %f but over budget gives %v", from.Record, to.Record, expected, te)
+		}
+		_, cost, ok, err = g.TraverseBidirectionalContext(context.Background(), anyPath{}, anyPath{}, from, to, 3)
+		if !errors.As(err, &te) || te.LowerBound > expected || (ok && cost < expected) {
+			t.Errorf("%s to %s costs %f but over budget from both ends gives %f (%t), %v", from.Record, to.Record, expected, cost, ok, err)
+		}
+	}
+}
+
+func TestTraverseCanceled(t *testing.T) {
+	g, nodes := randomGraph[anyPath](rand.New(rand.NewSource(12)), 50)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, ok, err := g.TraverseContext(ctx, anyPath{}, nodes[0], nodes[25], 0)
+	if ok || !errors.Is(err, ErrCanceled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled traversal gives %t, %v", ok, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	_, _, ok, err = g.TraverseBidirectionalContext(ctx, anyPath{}, anyPath{}, nodes[0], nodes[25], 0)
+	if ok || !errors.Is(err, ErrCanceled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("traversal past its deadline gives %t, %v", ok, err)
+	}
+}
@@ -0,0 +1,138 @@
+package graph
+
+// Connectivity is judged on the undirected graph underlying a Graph: two
+// nodes are linked if a vertex runs between them in either direction.
+
+// Link is an undirected connection between two nodes.
+type Link[R NodeRecord] struct {
+	A, B *Node[R]
+}
+
+// HopGraph returns a new graph whose nodes are the stops of g, sharing their
+// records, with a vertex for each hop between them (see Hops) costing no
+// more than maxCost, costing vertices as Overlay does.
+func (g *Graph[R, S]) HopGraph(isStop func(*Node[R]) bool, allow func(*Vertex[R]) bool, vertexCost func(*Vertex[R]) float64, maxCost float64) *Graph[R, S] {
+	return g.Overlay(isStop, allow, vertexCost, maxCost).Graph
+}
+
+// neighbours returns each node's distinct neighbours in the undirected
+// graph.
+func (g *Graph[R, S]) neighbours() map[*Node[R]][]*Node[R] {
+	result := make(map[*Node[R]][]*Node[R])
+	linked := make(map[Link[R]]bool)
+	for _, n := range g.nodes {
+		for i := 0; i < n.degree(); i++ {
+			v := n.vertex(i)
+			if v.From == v.To || linked[Link[R]{v.From, v.To}] {
+				continue
+			}
+			linked[Link[R]{v.From, v.To}] = true
+			linked[Link[R]{v.To, v.From}] = true
+			result[v.From] = append(result[v.From], v.To)
+			result[v.To] = append(result[v.To], v.From)
+		}
+	}
+	return result
+}
+
+// Components returns the connected components of the graph, each listing
+// its nodes in the order they were created.
+func (g *Graph[R, S]) Components() (components [][]*Node[R]) {
+	components = make([][]*Node[R], 0)
+	neighbours := g.neighbours()
+	component := make(map[*Node[R]]int)
+
+	for _, n := range g.nodes {
+		if _, found := component[n]; found {
+			continue
+		}
+		index := len(components)
+		component[n] = index
+		for stack := []*Node[R]{n}; len(stack) > 0; {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, next := range neighbours[current] {
+				if _, found := component[next]; !found {
+					component[next] = index
+					stack = append(stack, next)
+				}
+			}
+		}
+		components = append(components, make([]*Node[R], 0))
+	}
+
+	for _, n := range g.nodes {
+		components[component[n]] = append(components[component[n]], n)
+	}
+	return
+}
+
+// CutPoints returns the bridges, links whose loss would disconnect the
+// graph further, and the articulation points, nodes whose loss would do the
+// same, using Tarjan's depth-first lowlink numbering.
+func (g *Graph[R, S]) CutPoints() (bridges []Link[R], articulations []*Node[R]) {
+	bridges = make([]Link[R], 0)
+	articulations = make([]*Node[R], 0)
+	neighbours := g.neighbours()
+	order := make(map[*Node[R]]int)
+	low := make(map[*Node[R]]int)
+	isArticulation := make(map[*Node[R]]bool)
+
+	var visit func(n, parent *Node[R])
+	visit = func(n, parent *Node[R]) {
+		order[n] = len(order) + 1
+		low[n] = order[n]
+		children := 0
+		for _, next := range neighbours[n] {
+			if next == parent {
+				continue
+			}
+			if order[next] != 0 {
+				if order[next] < low[n] {
+					low[n] = order[next]
+				}
+				continue
+			}
+
+			children++
+			visit(next, n)
+			if low[next] < low[n] {
+				low[n] = low[next]
+			}
+			if low[next] > order[n] {
+				bridges = append(bridges, Link[R]{n, next})
+			}
+			if parent != nil && low[next] >= order[n] {
+				isArticulation[n] = true
+			}
+		}
+		if parent == nil && children > 1 {
+			isArticulation[n] = true
+		}
+	}
+
+	for _, n := range g.nodes {
+		if order[n] == 0 {
+			visit(n, nil)
+		}
+	}
+	for _, n := range g.nodes {
+		if isArticulation[n] {
+			articulations = append(articulations, n)
+		}
+	}
+	return
+}
+
+// Bridges returns the links whose loss would disconnect the graph further.
+func (g *Graph[R, S]) Bridges() []Link[R] {
+	bridges, _ := g.CutPoints()
+	return bridges
+}
+
+// ArticulationPoints returns the nodes whose loss would disconnect the
+// graph further.
+func (g *Graph[R, S]) ArticulationPoints() []*Node[R] {
+	_, articulations := g.CutPoints()
+	return articulations
+}
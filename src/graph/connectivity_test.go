@@ -0,0 +1,192 @@
+package graph
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+type name string
+
+func (n name) String() string {
+	return string(n)
+}
+
+// two triangles joined through d, which also leads on to e, plus an island
+func exampleGraph() (*Graph[name, anyPath], map[string]*Node[name]) {
+	g := NewGraph[name, anyPath]()
+	nodes := make(map[string]*Node[name])
+	for _, label := range []string{"a", "b", "c", "d", "e", "f", "g", "island"} {
+		nodes[label] = g.NewNode(name(label))
+	}
+	for _, link := range [][2]string{
+		{"a", "b"}, {"b", "c"}, {"c", "a"},
+		{"c", "d"},
+		{"d", "f"}, {"f", "g"}, {"g", "d"},
+		{"d", "e"},
+	} {
+		g.ConnectBi(nodes[link[0]], nodes[link[1]], 1.0)
+	}
+	return g, nodes
+}
+
+func TestComponents(t *testing.T) {
+	g, nodes := exampleGraph()
+	components := g.Components()
+	if len(components) != 2 || len(components[0]) != 7 || len(components[1]) != 1 || components[1][0] != nodes["island"] {
+		t.Errorf("unexpected components %v", components)
+	}
+}
+
+func TestCutPoints(t *testing.T) {
+	g, nodes := exampleGraph()
+	bridges, articulations := g.CutPoints()
+
+	if len(bridges) != 2 {
+		t.Fatalf("expected 2 bridges, found %v", bridges)
+	}
+	for _, expected := range []Link[name]{{nodes["c"], nodes["d"]}, {nodes["d"], nodes["e"]}} {
+		found := false
+		for _, b := range bridges {
+			found = found || b == expected || b == Link[name]{expected.B, expected.A}
+		}
+		if !found {
+			t.Errorf("bridge %s-%s missing from %v", expected.A.Record, expected.B.Record, bridges)
+		}
+	}
+
+	if len(articulations) != 2 || articulations[0] != nodes["c"] || articulations[1] != nodes["d"] {
+		t.Errorf("expected articulation points c and d, found %v", articulations)
+	}
+}
+
+func TestHopGraph(t *testing.T) {
+	// stops a and c linked through a waypoint b, and c to d directly
+	g := NewGraph[name, anyPath]()
+	a, b, c, d := g.NewNode(name("a")), g.NewNode(name("b")), g.NewNode(name("c")), g.NewNode(name("d"))
+	g.ConnectBi(a, b, 2.0)
+	g.ConnectBi(b, c, 2.0)
+	g.ConnectBi(c, d, 5.0)
+	isStop := func(n *Node[name]) bool { return n != b }
+
+	if components := g.HopGraph(isStop, nil, nil, 4.0).Components(); len(components) != 2 {
+		t.Errorf("range 4 should leave d isolated, not %v", components)
+	}
+	if components := g.HopGraph(isStop, nil, nil, 5.0).Components(); len(components) != 1 || len(components[0]) != 3 {
+		t.Errorf("range 5 should connect all three stops, not %v", components)
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	r := rand.New(rand.NewSource(9))
+	g, nodes := randomGraph[anyPath](r, 120)
+	isStop := func(n *Node[name]) bool { return n.Id()%3 == 0 }
+	o := g.Overlay(isStop, nil, nil, 25.0)
+
+	for trial := 0; trial < 100; trial++ {
+		from, to := nodes[3*r.Intn(40)], nodes[3*r.Intn(40)]
+		overPath, cost, ok := o.Traverse(anyPath{}, o.NodeFor(from), o.NodeFor(to))
+		if !ok {
+			continue
+		}
+		path := o.Expand(overPath)
+		checkPath(t, path, from, to, cost)
+
+		// no more than 25 between stops
+		sinceStop := 0.0
+		for i := 1; i < len(path); i++ {
+			if sinceStop += path[i-1].VertexTo(path[i]).Cost; sinceStop > 25.0 {
+				t.Errorf("path %v goes %f without a stop", path, sinceStop)
+			}
+			if isStop(path[i]) {
+				sinceStop = 0.0
+			}
+		}
+	}
+}
+
+// TestOverlayUpdate changes a graph again and again, updating an overlay of
+// it each time, and checks that the overlay's hops are those of an overlay
+// made afresh.
+func TestOverlayUpdate(t *testing.T) {
+	r := rand.New(rand.NewSource(11))
+	g, nodes := randomGraph[anyPath](r, 120)
+	stops := make(map[*Node[name]]bool)
+	for _, n := range nodes {
+		stops[n] = r.Intn(3) == 0
+	}
+	isStop := func(n *Node[name]) bool { return stops[n] }
+	g.Freeze()
+	o := g.Overlay(isStop, nil, nil, 25.0)
+
+	hopCosts := func(o *Overlay[name, anyPath]) map[[2]*Node[name]]float64 {
+		costs := make(map[[2]*Node[name]]float64)
+		for _, n := range o.Nodes() {
+			for i := 0; i < n.degree(); i++ {
+				v := n.vertex(i)
+				costs[[2]*Node[name]{o.base[n.id], o.base[v.To.id]}] = v.Cost
+			}
+		}
+		return costs
+	}
+
+	for round := 0; round < 20; round++ {
+		for k := 0; k < 2; k++ {
+			n := g.NewNode(name(fmt.Sprintf("r%d.%d", round, k)))
+			stops[n] = k == 0
+			for c := 0; c < 2; c++ {
+				g.ConnectBi(n, nodes[r.Intn(len(nodes))], float64(1+r.Intn(20)))
+			}
+			nodes = append(nodes, n)
+		}
+		doomed := nodes[r.Intn(len(nodes))]
+		g.RemoveNodes(doomed)
+		kept := nodes[:0]
+		for _, n := range nodes {
+			if n != doomed {
+				kept = append(kept, n)
+			}
+		}
+		nodes = kept
+
+		near := g.Thawed()
+		g.Freeze()
+		o.Update(near)
+		updated, fresh := hopCosts(o), hopCosts(g.Overlay(isStop, nil, nil, 25.0))
+		if len(updated) != len(fresh) {
+			t.Errorf("round %d: updated overlay has %d hops rather than %d", round, len(updated), len(fresh))
+		}
+		for hop, cost := range fresh {
+			if updated[hop] != cost {
+				t.Errorf("round %d: hop from %s to %s costs %f updated rather than %f", round, hop[0].Record, hop[1].Record, updated[hop], cost)
+			}
+		}
+		for hop := range updated {
+			if len(o.Expand([]*Node[name]{o.NodeFor(hop[0]), o.NodeFor(hop[1])})) < 2 {
+				t.Errorf("round %d: hop from %s to %s does not expand", round, hop[0].Record, hop[1].Record)
+			}
+		}
+	}
+}
+
+func TestRemoveNodes(t *testing.T) {
+	g, nodes := exampleGraph()
+	g.RemoveNodes(nodes["d"])
+
+	if components := g.Components(); len(components) != 4 {
+		t.Errorf("removing d should leave 4 components, not %v", components)
+	}
+	for _, n := range g.Nodes() {
+		for i := 0; i < n.degree(); i++ {
+			if v := n.vertex(i); v.From == nodes["d"] || v.To == nodes["d"] {
+				t.Errorf("vertex %s to %s survived", v.From.Record, v.To.Record)
+			}
+		}
+	}
+	if v := nodes["c"].VertexTo(nodes["d"]); v != nil {
+		t.Errorf("c still leads to d")
+	}
+	if v := nodes["c"].VertexTo(nodes["a"]); v == nil {
+		t.Errorf("c no longer leads to a")
+	}
+}
@@ -0,0 +1,316 @@
+package graph
+
+import (
+	"math"
+	sheap "slice_heap"
+	"sort"
+)
+
+// Witness searches give up after settling this many nodes or taking this
+// many arcs, at the price of a few shortcuts that a longer search would have
+// shown to be unneeded.
+const (
+	WITNESS_SEARCH_LIMIT = 200
+	WITNESS_HOP_LIMIT    = 4
+)
+
+// shortcut is an arc of a contraction hierarchy: a vertex of the graph, or
+// two arcs joined through the node whose contraction made it.
+type shortcut struct {
+	cost float64
+	via  int // node id, or -1 for a vertex of the graph
+}
+
+type arc struct {
+	to   int
+	cost float64
+}
+
+// A Hierarchy is a contracted copy of a graph that finds cheapest paths
+// between its nodes by searching only towards nodes contracted later, from
+// both ends. It uses each vertex's fixed Cost and so takes no private state;
+// whatever a state would forbid must already be left out of the graph, as
+// an Overlay does for a range. It describes the graph as it was when made.
+type Hierarchy[R NodeRecord, S TraverseState[R, S]] struct {
+	nodes []*Node[R]
+	up    [][]arc             // by node id, arcs to nodes contracted later
+	down  [][]arc             // by node id, arcs from nodes contracted later, reversed
+	arcs  map[[2]int]shortcut // every arc by the ids of its ends
+}
+
+// contraction is the working state of building a Hierarchy.
+type contraction struct {
+	out, in    []map[int]float64 // by node id, arcs among nodes not yet contracted
+	arcs       map[[2]int]shortcut
+	neighbours []int // by node id, neighbours already contracted
+}
+
+// byId returns the ids of a node's arcs in order, so that shortcuts are
+// made in the same order every time.
+func byId(arcs map[int]float64) []int {
+	ids := make([]int, 0, len(arcs))
+	for id := range arcs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (c *contraction) addArc(from, to int, cost float64, via int) {
+	if existing, found := c.arcs[[2]int{from, to}]; found && existing.cost <= cost {
+		return
+	}
+	c.arcs[[2]int{from, to}] = shortcut{cost, via}
+	c.out[from][to] = cost
+	c.in[to][from] = cost
+}
+
+type witnessState struct {
+	node, hops int
+	cost       float64
+}
+
+// witnessCosts returns the cost of reaching nodes from a node without
+// passing through the one being contracted, as far as maxCost or until the
+// targets are all reached.
+func (c *contraction) witnessCosts(from, skipped int, maxCost float64, targets map[int]bool) map[int]float64 {
+	costs := map[int]float64{from: 0.0}
+	settled := make(map[int]bool)
+	sh := sheap.NewSliceHeap(func(a, b witnessState) bool { return a.cost < b.cost })
+	sh.PushItem(witnessState{from, 0, 0.0})
+
+	for left := len(targets); !sh.IsEmpty() && left > 0 && len(settled) < WITNESS_SEARCH_LIMIT; {
+		current := sh.PopItem()
+		if settled[current.node] {
+			continue
+		}
+		settled[current.node] = true
+		if current.cost > maxCost {
+			break
+		}
+		if targets[current.node] {
+			left--
+		}
+		if current.hops == WITNESS_HOP_LIMIT {
+			continue
+		}
+		for next, cost := range c.out[current.node] {
+			total := current.cost + cost
+			if known, found := costs[next]; next != skipped && (!found || total < known) {
+				costs[next] = total
+				sh.PushItem(witnessState{next, current.hops + 1, total})
+			}
+		}
+	}
+	return costs
+}
+
+// candidates returns, for each node with an arc to n, the nodes n has arcs
+// to that are not already reached more cheaply by an arc of their own: the
+// shortcuts that contracting n might need. That arc is the witness most
+// often found, so it is tried before searching.
+func (c *contraction) candidates(n int) (froms []int, tos [][]int) {
+	for _, from := range byId(c.in[n]) {
+		inCost := c.in[n][from]
+		var needed []int
+		for _, to := range byId(c.out[n]) {
+			if direct, found := c.out[from][to]; to != from && (!found || direct > inCost+c.out[n][to]) {
+				needed = append(needed, to)
+			}
+		}
+		if len(needed) > 0 {
+			froms, tos = append(froms, from), append(tos, needed)
+		}
+	}
+	return
+}
+
+// shortcuts returns the arcs that contracting a node needs so that no
+// cheapest path between the others is lost.
+func (c *contraction) shortcuts(n int) (needed [][2]int) {
+	froms, tos := c.candidates(n)
+	for i, from := range froms {
+		inCost := c.in[n][from]
+		targets := make(map[int]bool)
+		maxCost := 0.0
+		for _, to := range tos[i] {
+			targets[to] = true
+			maxCost = math.Max(maxCost, inCost+c.out[n][to])
+		}
+
+		costs := c.witnessCosts(from, n, maxCost, targets)
+		for _, to := range tos[i] {
+			if witness, found := costs[to]; !found || witness > inCost+c.out[n][to] {
+				needed = append(needed, [2]int{from, to})
+			}
+		}
+	}
+	return
+}
+
+// priority orders nodes for contraction: those adding fewer arcs than they
+// take away, and with fewer neighbours already contracted, go first. The
+// arcs added are overestimated by leaving out the witness searches, which
+// would take most of the time spent contracting.
+func (c *contraction) priority(n int) int {
+	_, tos := c.candidates(n)
+	priority := c.neighbours[n] - len(c.in[n]) - len(c.out[n])
+	for _, t := range tos {
+		priority += len(t)
+	}
+	return priority
+}
+
+func (c *contraction) contract(n int) {
+	for _, pair := range c.shortcuts(n) {
+		c.addArc(pair[0], pair[1], c.in[n][pair[0]]+c.out[n][pair[1]], n)
+	}
+	for from := range c.in[n] {
+		delete(c.out[from], n)
+		c.neighbours[from]++
+	}
+	for to := range c.out[n] {
+		delete(c.in[to], n)
+		c.neighbours[to]++
+	}
+}
+
+// newContraction starts contracting a graph, with an arc for each of its
+// vertices other than loops.
+func newContraction[R NodeRecord, S TraverseState[R, S]](g *Graph[R, S]) *contraction {
+	count := len(g.nodes)
+	c := &contraction{make([]map[int]float64, count), make([]map[int]float64, count),
+		make(map[[2]int]shortcut), make([]int, count)}
+	for i := range g.nodes {
+		c.out[i], c.in[i] = make(map[int]float64), make(map[int]float64)
+	}
+	for _, n := range g.nodes {
+		for i := 0; i < n.degree(); i++ {
+			if v := n.vertex(i); v.To != n {
+				c.addArc(n.id, v.To.id, v.Cost, -1)
+			}
+		}
+	}
+	return c
+}
+
+type contractionCandidate struct {
+	node, priority, sequence int
+}
+
+// Contract builds a contraction hierarchy of the graph, contracting its
+// nodes one at a time in the order of an estimate, updated as it goes, of
+// how many arcs each would add.
+func (g *Graph[R, S]) Contract() *Hierarchy[R, S] {
+	count := len(g.nodes)
+	c := newContraction(g)
+	sh := sheap.NewSliceHeap(func(a, b contractionCandidate) bool {
+		if a.priority != b.priority {
+			return a.priority < b.priority
+		}
+		return a.sequence < b.sequence
+	})
+	for i := range g.nodes {
+		sh.PushItem(contractionCandidate{i, c.priority(i), i})
+	}
+
+	rank := make([]int, count)
+	for contracted := 0; !sh.IsEmpty(); {
+		candidate := sh.PopItem()
+		// priorities go stale as neighbours are contracted, so one that has
+		// risen past the next candidate's goes back for another turn
+		if priority := c.priority(candidate.node); !sh.IsEmpty() && priority > sh.Peek().priority {
+			candidate.priority = priority
+			sh.PushItem(candidate)
+			continue
+		}
+		c.contract(candidate.node)
+		rank[candidate.node] = contracted
+		contracted++
+	}
+
+	h := &Hierarchy[R, S]{append([]*Node[R](nil), g.nodes...), make([][]arc, count), make([][]arc, count), c.arcs}
+	ends := make([][2]int, 0, len(c.arcs))
+	for e := range c.arcs {
+		ends = append(ends, e)
+	}
+	sort.Slice(ends, func(i, j int) bool {
+		return ends[i][0] < ends[j][0] || (ends[i][0] == ends[j][0] && ends[i][1] < ends[j][1])
+	})
+	for _, e := range ends {
+		if cost := c.arcs[e].cost; rank[e[0]] < rank[e[1]] {
+			h.up[e[0]] = append(h.up[e[0]], arc{e[1], cost})
+		} else {
+			h.down[e[1]] = append(h.down[e[1]], arc{e[0], cost})
+		}
+	}
+	return h
+}
+
+// upward searches the hierarchy from a node along arcs, returning the cost
+// of reaching each node found and the node before it.
+func upward(arcs [][]arc, from int) (costs map[int]float64, previous map[int]int) {
+	costs, previous = map[int]float64{from: 0.0}, make(map[int]int)
+	settled := make(map[int]bool)
+	sh := sheap.NewSliceHeap(func(a, b arc) bool { return a.cost < b.cost })
+	sh.PushItem(arc{from, 0.0})
+
+	for !sh.IsEmpty() {
+		current := sh.PopItem()
+		if settled[current.to] {
+			continue
+		}
+		settled[current.to] = true
+		for _, a := range arcs[current.to] {
+			total := current.cost + a.cost
+			if known, found := costs[a.to]; !found || total < known {
+				costs[a.to], previous[a.to] = total, current.to
+				sh.PushItem(arc{a.to, total})
+			}
+		}
+	}
+	return
+}
+
+// unpack appends the nodes an arc passes through after its start.
+func (h *Hierarchy[R, S]) unpack(from, to int, path []*Node[R]) []*Node[R] {
+	if via := h.arcs[[2]int{from, to}].via; via >= 0 {
+		return h.unpack(via, to, h.unpack(from, via, path))
+	}
+	return append(path, h.nodes[to])
+}
+
+// Path finds the cheapest path between two nodes of the graph the
+// hierarchy was made from. Concurrent calls are safe.
+func (h *Hierarchy[R, S]) Path(from, to *Node[R]) (path []*Node[R], totalCost float64, ok bool) {
+	forwardCosts, forwardPrevious := upward(h.up, from.id)
+	backwardCosts, backwardPrevious := upward(h.down, to.id)
+
+	meeting := -1
+	totalCost = math.Inf(1)
+	for n, forwardCost := range forwardCosts {
+		if backwardCost, found := backwardCosts[n]; found && (forwardCost+backwardCost < totalCost ||
+			(forwardCost+backwardCost == totalCost && n < meeting)) {
+			meeting, totalCost = n, forwardCost+backwardCost
+		}
+	}
+	if meeting < 0 {
+		return nil, 0.0, false
+	}
+
+	// the arcs up from the start to where the searches met, then down
+	ups := make([]int, 0)
+	for n := meeting; n != from.id; n = forwardPrevious[n] {
+		ups = append(ups, n)
+	}
+	path = []*Node[R]{from}
+	last := from.id
+	for i := len(ups) - 1; i >= 0; i-- {
+		path = h.unpack(last, ups[i], path)
+		last = ups[i]
+	}
+	for n := meeting; n != to.id; n = backwardPrevious[n] {
+		path = h.unpack(n, backwardPrevious[n], path)
+	}
+	return path, totalCost, true
+}
@@ -0,0 +1,117 @@
+package graph
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestContract(t *testing.T) {
+	r := rand.New(rand.NewSource(10))
+	g, nodes := randomGraph[anyPath](r, 300)
+	// one-way vertices, so that arcs up and down differ
+	for i := 0; i < 200; i++ {
+		g.ConnectUni(nodes[r.Intn(len(nodes))], nodes[r.Intn(len(nodes))], float64(1+r.Intn(20)))
+	}
+	island := g.NewNode(name("island"))
+	g.Freeze()
+	h := g.Contract()
+
+	for trial := 0; trial < 300; trial++ {
+		from, to := nodes[r.Intn(len(nodes))], nodes[r.Intn(len(nodes))]
+		_, expected, _ := g.Traverse(anyPath{}, from, to)
+		path, cost, ok := h.Path(from, to)
+		if !ok || cost != expected {
+			t.Errorf("%s to %s costs %f (%t) in the hierarchy but %f in the graph", from.Record, to.Record, cost, ok, expected)
+			continue
+		}
+		checkPath(t, path, from, to, cost)
+	}
+
+	if _, _, ok := h.Path(nodes[0], island); ok {
+		t.Errorf("found a path to an unconnected node")
+	}
+}
+
+// witnessGraph links a to b through n, at a cost of 2, and also by a chain
+// of hops costing 1.5 in all, with a fan of cheap spokes from a that a
+// witness search settles first.
+func witnessGraph(hops, spokes int) (g *Graph[name, anyPath], a, n, b *Node[name]) {
+	g = NewGraph[name, anyPath]()
+	a, n, b = g.NewNode("a"), g.NewNode("n"), g.NewNode("b")
+	g.ConnectUni(a, n, 1.0)
+	g.ConnectUni(n, b, 1.0)
+	last := a
+	for i := 1; i < hops; i++ {
+		next := g.NewNode(name(fmt.Sprintf("w%d", i)))
+		g.ConnectUni(last, next, 1.5/float64(hops))
+		last = next
+	}
+	g.ConnectUni(last, b, 1.5/float64(hops))
+	for i := 0; i < spokes; i++ {
+		g.ConnectUni(a, g.NewNode(name(fmt.Sprintf("s%d", i))), 0.001)
+	}
+	return
+}
+
+// TestWitnessLimits checks that contracting n needs no shortcut from a to b
+// while the witness search can find the cheaper chain, and adds one once
+// the chain takes more than WITNESS_HOP_LIMIT arcs or the spokes use up
+// WITNESS_SEARCH_LIMIT settled nodes, without changing any cheapest path.
+func TestWitnessLimits(t *testing.T) {
+	cases := []struct {
+		hops, spokes int
+		shortcut     bool
+	}{
+		{WITNESS_HOP_LIMIT, 0, false},
+		{WITNESS_HOP_LIMIT + 1, 0, true},
+		{2, WITNESS_SEARCH_LIMIT - 10, false},
+		{2, WITNESS_SEARCH_LIMIT, true},
+	}
+	for _, tc := range cases {
+		g, a, n, b := witnessGraph(tc.hops, tc.spokes)
+		needed := newContraction(g).shortcuts(n.id)
+		if shortcut := len(needed) == 1 && needed[0] == [2]int{a.id, b.id}; shortcut != tc.shortcut || len(needed) > 1 {
+			t.Errorf("%d hops and %d spokes: contracting n needs shortcuts %v", tc.hops, tc.spokes, needed)
+		}
+
+		h := g.Contract()
+		if path, cost, ok := h.Path(a, b); !ok || cost != 1.5 {
+			t.Errorf("%d hops and %d spokes: a to b costs %f (%t) by %v in the hierarchy", tc.hops, tc.spokes, cost, ok, path)
+		}
+	}
+}
+
+// TestOneWayShortcut checks that a shortcut made through a node reached only
+// one way unpacks to the vertices it replaces, and only in their direction.
+func TestOneWayShortcut(t *testing.T) {
+	g := NewGraph[name, anyPath]()
+	// n goes first, so that contracting it adds the shortcut
+	n := g.NewNode("n")
+	a, b := g.NewNode("a"), g.NewNode("b")
+	g.ConnectUni(a, n, 1.0)
+	g.ConnectUni(n, b, 2.0)
+	h := g.Contract()
+
+	if arc, found := h.arcs[[2]int{a.id, b.id}]; !found || arc.via != n.id || arc.cost != 3.0 {
+		t.Fatalf("the arc from a to b is %+v (%t) rather than a shortcut through n", arc, found)
+	}
+	path, cost, ok := h.Path(a, b)
+	if !ok || cost != 3.0 || len(path) != 3 || path[0] != a || path[1] != n || path[2] != b {
+		t.Errorf("a to b costs %f (%t) by %v rather than 3 by a, n, b", cost, ok, path)
+	}
+	if path, _, ok := h.Path(b, a); ok {
+		t.Errorf("b to a goes against the vertices by %v", path)
+	}
+}
+
+func BenchmarkContractedPath(b *testing.B) {
+	r := rand.New(rand.NewSource(7))
+	g, nodes := randomGraph[anyPath](r, 2000)
+	h := g.Contract()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Path(nodes[i%len(nodes)], nodes[(i*7+1000)%len(nodes)])
+	}
+}
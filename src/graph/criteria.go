@@ -0,0 +1,190 @@
+package graph
+
+import (
+	"context"
+	it "immutable_tree"
+	sheap "slice_heap"
+)
+
+// A Criterion prices a vertex for a path taking it from the given private
+// state, for traversals that weigh more than the single Cost of a vertex.
+// Criteria must not be negative.
+type Criterion[R NodeRecord, S any] func(state S, v *Vertex[R]) float64
+
+// Attribute returns the criterion of a vertex's i'th attribute, taking it
+// to be 0 for vertices with fewer.
+func Attribute[R NodeRecord, S any](i int) Criterion[R, S] {
+	return func(state S, v *Vertex[R]) float64 {
+		if attributes := v.Attributes(); i < len(attributes) {
+			return attributes[i]
+		}
+		return 0.0
+	}
+}
+
+// Scalarise returns the criterion that is the weighted sum of others.
+func Scalarise[R NodeRecord, S any](weights []float64, criteria []Criterion[R, S]) Criterion[R, S] {
+	if len(weights) != len(criteria) {
+		panic("scalarising needs a weight for each criterion")
+	}
+	return func(state S, v *Vertex[R]) (total float64) {
+		for i, c := range criteria {
+			total += weights[i] * c(state, v)
+		}
+		return
+	}
+}
+
+// label is a path being searched by several criteria.
+type label[R NodeRecord, S TraverseState[R, S]] struct {
+	costs        []float64 // by criterion
+	sequence     int
+	node         *Node[R]
+	visited      *VisitedList[R]
+	before       *it.Tree // the nodes before this one when needed to rule out cycles
+	privateState S
+}
+
+// labelLessThan orders labels lexicographically by their costs.
+func labelLessThan[R NodeRecord, S TraverseState[R, S]](l1, l2 *label[R, S]) bool {
+	for i, c := range l1.costs {
+		if c != l2.costs[i] {
+			return c < l2.costs[i]
+		}
+	}
+	return l1.sequence < l2.sequence
+}
+
+// outdoes reports whether a label kept at a node leaves no use for another
+// arriving there later, which label order ensures costs no less. For a
+// Pareto front, which has two criteria, the other must also cost no less by
+// the second. Where private states dominate, the kept label's state must
+// dominate too, except at the target, where states no longer matter.
+func outdoes[R NodeRecord, S TraverseState[R, S]](kept, other *label[R, S], pareto, atTarget bool) bool {
+	if pareto && kept.costs[1] > other.costs[1] {
+		return false
+	}
+	if dominating, ok := any(kept.privateState).(DominatingTraverseState[S]); ok && !atTarget {
+		return dominating.Dominates(other.privateState)
+	}
+	return true
+}
+
+// labelSearch expands labels in order of their costs, keeping only those
+// that no label already kept at the same node outdoes, and returns those
+// kept at the target in the order found: all of them for a Pareto front,
+// otherwise the first. Labels are not expanded beyond the target.
+func (g *Graph[R, S]) labelSearch(ctx context.Context, privateState S, from, to *Node[R], criteria []Criterion[R, S], pareto bool, maxStates int) (found []*label[R, S], err error) {
+	kept := make([][]*label[R, S], len(g.nodes))
+	isOutdone := func(l *label[R, S]) bool {
+		for _, k := range kept[l.node.id] {
+			if outdoes(k, l, pareto, l.node == to) {
+				return true
+			}
+		}
+		return false
+	}
+	// Without dominance, a label back at a node of its path is outdone by
+	// the one kept there on the way, so only dominating states need their
+	// paths checked for cycles.
+	var before *it.Tree
+	if _, dominating := any(privateState).(DominatingTraverseState[S]); dominating {
+		before = it.NewTree()
+	}
+
+	sh := sheap.NewSliceHeap(labelLessThan[R, S])
+	sequence := 0
+	sh.PushItem(&label[R, S]{make([]float64, len(criteria)), sequence, from, &VisitedList[R]{from, nil}, before, privateState})
+	b := &budget{ctx, maxStates, 1}
+
+	for !sh.IsEmpty() {
+		if err = b.spent(sh.Peek().costs[0]); err != nil {
+			return
+		}
+		l := sh.PopItem()
+		if isOutdone(l) {
+			continue
+		}
+		kept[l.node.id] = append(kept[l.node.id], l)
+
+		if l.node == to {
+			found = append(found, l)
+			if !pareto {
+				return
+			}
+			continue
+		}
+
+		var onPath *it.Tree
+		if l.before != nil {
+			onPath = l.before.AddValue(l.node)
+		}
+
+		for i := 0; i < l.node.degree(); i++ {
+			vertex := l.node.vertex(i)
+			// every node on the path has a label kept there
+			if onPath != nil && len(kept[vertex.To.id]) > 0 && onPath.HasValue(vertex.To) {
+				continue
+			}
+			nextPrivateState, ok := l.privateState.TraverseStateHelper(vertex)
+			if !ok {
+				continue
+			}
+			costs := make([]float64, len(criteria))
+			for c, criterion := range criteria {
+				costs[c] = l.costs[c] + criterion(l.privateState, vertex)
+			}
+			sequence++
+			next := &label[R, S]{costs, sequence, vertex.To, &VisitedList[R]{vertex.To, l.visited}, onPath, nextPrivateState}
+			// a label outdone now would only be discarded when popped
+			if !isOutdone(next) {
+				sh.PushItem(next)
+				b.states++
+			}
+		}
+	}
+	return
+}
+
+// TraverseCriteria finds the path between two nodes that the private state
+// allows which costs least by the first of the criteria, breaking ties by
+// the second and so on, returning its cost by each. A single criterion made
+// by Scalarise finds the path with the least weighted sum. It gives up like
+// TraverseContext, and is safe to call concurrently like Traverse.
+func (g *Graph[R, S]) TraverseCriteria(ctx context.Context, privateState S, from, to *Node[R], criteria []Criterion[R, S], maxStates int) (path []*Node[R], costs []float64, ok bool, err error) {
+	if len(criteria) == 0 {
+		panic("traversing by criteria needs at least one")
+	}
+	found, err := g.labelSearch(ctx, privateState, from, to, criteria, false, maxStates)
+	if len(found) == 0 {
+		return nil, nil, false, err
+	}
+	return found[0].visited.MakeSlice(), found[0].costs, true, err
+}
+
+// A ParetoPath is a path that no other costs less by one of two criteria
+// without costing more by the other.
+type ParetoPath[R NodeRecord] struct {
+	Path  []*Node[R]
+	Costs [2]float64
+}
+
+// ParetoFront finds every path between two nodes that the private state
+// allows and that no other beats by both of two criteria, one for each
+// pair of costs, in increasing order of the first. Where private states
+// dominate, a path is only pruned on the way by one whose state there
+// dominates its own, as in Traverse. It gives up like TraverseContext,
+// returning the paths found so far.
+func (g *Graph[R, S]) ParetoFront(ctx context.Context, privateState S, from, to *Node[R], first, second Criterion[R, S], maxStates int) (front []ParetoPath[R], err error) {
+	found, err := g.labelSearch(ctx, privateState, from, to, []Criterion[R, S]{first, second}, true, maxStates)
+	front = make([]ParetoPath[R], 0, len(found))
+	for _, l := range found {
+		front = append(front, ParetoPath[R]{l.visited.MakeSlice(), [2]float64{l.costs[0], l.costs[1]}})
+	}
+	return
+}
+
+// CostCriterion is the criterion of each vertex's Cost.
+func CostCriterion[R NodeRecord, S any](state S, v *Vertex[R]) float64 {
+	return v.Cost
+}
@@ -0,0 +1,148 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func steps(state anyPath, v *Vertex[name]) float64 {
+	return 1.0
+}
+
+func TestTraverseCriteria(t *testing.T) {
+	r := rand.New(rand.NewSource(14))
+	g, nodes := randomGraph[anyPath](r, 150)
+	cost := CostCriterion[name, anyPath]
+	for trial := 0; trial < 20; trial++ {
+		from, to := nodes[r.Intn(len(nodes))], nodes[r.Intn(len(nodes))]
+		_, expected, _ := g.Traverse(anyPath{}, from, to)
+		path, costs, ok, err := g.TraverseCriteria(context.Background(), anyPath{}, from, to, []Criterion[name, anyPath]{cost}, 0)
+		if err != nil || !ok || costs[0] != expected {
+			t.Errorf("%s to %s costs %v (%t, %v) by criteria but %f", from.Record, to.Record, costs, ok, err, expected)
+			continue
+		}
+		checkPath(t, path, from, to, expected)
+
+		// costs are whole and small, so a heavy enough weight on the
+		// number of steps orders paths as lexicographically
+		_, lexicographic, _, _ := g.TraverseCriteria(context.Background(), anyPath{}, from, to, []Criterion[name, anyPath]{steps, cost}, 0)
+		_, weighted, _, _ := g.TraverseCriteria(context.Background(), anyPath{}, from, to,
+			[]Criterion[name, anyPath]{Scalarise([]float64{1e6, 1.0}, []Criterion[name, anyPath]{steps, cost})}, 0)
+		if lexicographic[0]*1e6+lexicographic[1] != weighted[0] {
+			t.Errorf("%s to %s costs %v lexicographically but %f weighted", from.Record, to.Record, lexicographic, weighted[0])
+		}
+	}
+}
+
+// simplePaths returns the cost and number of steps of every path between
+// two nodes that repeats no node.
+func simplePaths(from, to *Node[name], onPath map[*Node[name]]bool, cost, count float64, found map[[2]float64]bool) {
+	if from == to {
+		found[[2]float64{cost, count}] = true
+		return
+	}
+	onPath[from] = true
+	for i := 0; i < from.degree(); i++ {
+		if v := from.vertex(i); !onPath[v.To] {
+			simplePaths(v.To, to, onPath, cost+v.Cost, count+1, found)
+		}
+	}
+	delete(onPath, from)
+}
+
+// TestCriteriaWithoutCycles searches by cost with states that never
+// dominate, so that only the labels' paths keep them from going round in
+// circles, which a Pareto front would do for ever.
+func TestCriteriaWithoutCycles(t *testing.T) {
+	g, nodes := randomGraph[neverDominated](rand.New(rand.NewSource(21)), 8)
+	r := rand.New(rand.NewSource(22))
+	criteria := []Criterion[name, neverDominated]{CostCriterion[name, neverDominated]}
+	for trial := 0; trial < 20; trial++ {
+		from, to := nodes[r.Intn(len(nodes))], nodes[r.Intn(len(nodes))]
+		_, expected, _ := g.Traverse(neverDominated{}, from, to)
+		path, costs, ok, err := g.TraverseCriteria(context.Background(), neverDominated{}, from, to, criteria, 0)
+		if !ok || err != nil || costs[0] != expected {
+			t.Errorf("%s to %s costs %v (%t, %v) rather than %f", from.Record, to.Record, costs, ok, err, expected)
+			continue
+		}
+		checkPath(t, path, from, to, expected)
+
+		front, err := g.ParetoFront(context.Background(), neverDominated{}, from, to, criteria[0], criteria[0], 0)
+		if len(front) != 1 || err != nil || front[0].Costs[0] != expected {
+			t.Errorf("%s to %s has front %v (%v) rather than one path costing %f", from.Record, to.Record, front, err, expected)
+			continue
+		}
+		checkPath(t, front[0].Path, from, to, expected)
+	}
+}
+
+func TestParetoFront(t *testing.T) {
+	r := rand.New(rand.NewSource(15))
+	g, nodes := randomGraph[anyPath](r, 9)
+	for trial := 0; trial < 20; trial++ {
+		from, to := nodes[r.Intn(len(nodes))], nodes[r.Intn(len(nodes))]
+		all := make(map[[2]float64]bool)
+		simplePaths(from, to, make(map[*Node[name]]bool), 0.0, 0.0, all)
+		expected := make([][2]float64, 0)
+		for c := range all {
+			dominated := false
+			for other := range all {
+				dominated = dominated || (other != c && other[0] <= c[0] && other[1] <= c[1])
+			}
+			if !dominated {
+				expected = append(expected, c)
+			}
+		}
+		sort.Slice(expected, func(i, j int) bool { return expected[i][0] < expected[j][0] })
+
+		front, err := g.ParetoFront(context.Background(), anyPath{}, from, to, CostCriterion[name, anyPath], steps, 0)
+		got := make([][2]float64, len(front))
+		for i, p := range front {
+			got[i] = p.Costs
+			checkPath(t, p.Path, from, to, p.Costs[0])
+			if float64(len(p.Path)-1) != p.Costs[1] {
+				t.Errorf("path %v has %d steps, not %f", p.Path, len(p.Path)-1, p.Costs[1])
+			}
+		}
+		if err != nil || fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Errorf("%s to %s has front %v (%v) rather than %v", from.Record, to.Record, got, err, expected)
+		}
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	g := NewGraphWithAttributes[name, anyPath](1)
+	a, b, c := g.NewNode("a"), g.NewNode("b"), g.NewNode("c")
+	g.ConnectBiWith(a, b, 1.0, []float64{5.0})
+	g.ConnectBiWith(b, c, 1.0, []float64{5.0})
+	g.ConnectBi(a, c, 3.0)
+	for _, frozen := range []bool{false, true} {
+		if frozen {
+			g.Freeze()
+		}
+		_, costs, _, _ := g.TraverseCriteria(context.Background(), anyPath{}, a, c, []Criterion[name, anyPath]{Attribute[name, anyPath](0)}, 0)
+		path, cost, _ := g.Traverse(anyPath{}, a, c)
+		if costs[0] != 0.0 || cost != 2.0 || len(path) != 3 {
+			t.Errorf("frozen %t: a to c costs %v by attribute and %f by cost via %v", frozen, costs, cost, path)
+		}
+	}
+
+	// attributes stay with their vertices as others come and go
+	d := g.NewNode("d")
+	g.ConnectUniWith(a, d, 1.0, []float64{7.0})
+	g.RemoveNodes(b)
+	for _, frozen := range []bool{false, true} {
+		if frozen {
+			g.Freeze()
+		}
+		if attributes := a.VertexTo(d).Attributes(); len(attributes) != 1 || attributes[0] != 7.0 {
+			t.Errorf("frozen %t: a to d has attributes %v rather than [7]", frozen, attributes)
+		}
+		if attributes := a.VertexTo(c).Attributes(); len(attributes) != 1 || attributes[0] != 0.0 {
+			t.Errorf("frozen %t: a to c has attributes %v rather than [0]", frozen, attributes)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package graph
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LocatedRecord is implemented by records of nodes with a place on the
+// earth, which the exporters use to lay the nodes out.
+type LocatedRecord interface {
+	ToLatLonDegrees() (lat, lon float64)
+}
+
+// exportWriter keeps the first error writing an export, after which it
+// writes nothing more.
+type exportWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *exportWriter) printf(format string, args ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
+
+// onRoute returns the nodes of a route and the steps between them, keyed by
+// the nodes each runs from and to.
+func onRoute[R NodeRecord](route []*Node[R]) (nodes map[*Node[R]]bool, steps map[Link[R]]bool) {
+	nodes, steps = make(map[*Node[R]]bool), make(map[Link[R]]bool)
+	for i, n := range route {
+		nodes[n] = true
+		if i > 0 {
+			steps[Link[R]{route[i-1], n}] = true
+		}
+	}
+	return
+}
+
+// WriteText writes the graph as a plain adjacency list: each node followed
+// by the nodes its vertices lead to and their costs.
+func (g *Graph[R, S]) WriteText(w io.Writer) error {
+	ew := &exportWriter{w: w}
+	for _, n := range g.nodes {
+		ew.printf("%s:\n", n.Record)
+		for i := 0; i < n.degree(); i++ {
+			v := n.vertex(i)
+			ew.printf("    %s @ %f\n", v.To.Record, v.Cost)
+			if v.From != n {
+				panic("non-matching node/vertex")
+			}
+		}
+	}
+	return ew.err
+}
+
+// The scale at which nodes with located records are pinned in DOT output,
+// where positions are in inches.
+const DOT_INCHES_PER_DEGREE = 1.0
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// WriteDOT writes the graph in Graphviz's DOT language, with each vertex
+// labelled with its cost and the nodes and vertices of route, which may be
+// nil, drawn in red. Nodes with located records are pinned at their
+// longitude and latitude, for laying out with neato.
+func (g *Graph[R, S]) WriteDOT(w io.Writer, name string, route []*Node[R]) error {
+	routeNodes, routeSteps := onRoute(route)
+	ew := &exportWriter{w: w}
+
+	ew.printf("digraph %s {\n", dotQuote(name))
+	for _, n := range g.nodes {
+		ew.printf("    n%d [label=%s", n.id, dotQuote(n.Record.String()))
+		if located, ok := any(n.Record).(LocatedRecord); ok {
+			lat, lon := located.ToLatLonDegrees()
+			ew.printf(", pos=\"%f,%f!\"", lon*DOT_INCHES_PER_DEGREE, lat*DOT_INCHES_PER_DEGREE)
+		}
+		if routeNodes[n] {
+			ew.printf(", color=red")
+		}
+		ew.printf("];\n")
+	}
+	for _, n := range g.nodes {
+		for i := 0; i < n.degree(); i++ {
+			v := n.vertex(i)
+			ew.printf("    n%d -> n%d [label=\"%.3f\"", v.From.id, v.To.id, v.Cost)
+			if routeSteps[Link[R]{v.From, v.To}] {
+				ew.printf(", color=red, penwidth=3")
+			}
+			ew.printf("];\n")
+		}
+	}
+	ew.printf("}\n")
+	return ew.err
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// WriteGraphML writes the graph as a GraphML document, with each node's
+// label, latitude and longitude when its record is located, each edge's
+// cost, and whether each is on route, which may be nil.
+func (g *Graph[R, S]) WriteGraphML(w io.Writer, name string, route []*Node[R]) error {
+	routeNodes, routeSteps := onRoute(route)
+	ew := &exportWriter{w: w}
+
+	ew.printf("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	ew.printf("<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n")
+	ew.printf("  <key id=\"label\" for=\"node\" attr.name=\"label\" attr.type=\"string\"/>\n")
+	ew.printf("  <key id=\"lat\" for=\"node\" attr.name=\"lat\" attr.type=\"double\"/>\n")
+	ew.printf("  <key id=\"lon\" for=\"node\" attr.name=\"lon\" attr.type=\"double\"/>\n")
+	ew.printf("  <key id=\"cost\" for=\"edge\" attr.name=\"cost\" attr.type=\"double\"/>\n")
+	ew.printf("  <key id=\"route\" for=\"all\" attr.name=\"route\" attr.type=\"boolean\"><default>false</default></key>\n")
+	ew.printf("  <graph id=\"%s\" edgedefault=\"directed\">\n", xmlEscape(name))
+	for _, n := range g.nodes {
+		ew.printf("    <node id=\"n%d\"><data key=\"label\">%s</data>", n.id, xmlEscape(n.Record.String()))
+		if located, ok := any(n.Record).(LocatedRecord); ok {
+			lat, lon := located.ToLatLonDegrees()
+			ew.printf("<data key=\"lat\">%f</data><data key=\"lon\">%f</data>", lat, lon)
+		}
+		if routeNodes[n] {
+			ew.printf("<data key=\"route\">true</data>")
+		}
+		ew.printf("</node>\n")
+	}
+	for _, n := range g.nodes {
+		for i := 0; i < n.degree(); i++ {
+			v := n.vertex(i)
+			ew.printf("    <edge source=\"n%d\" target=\"n%d\"><data key=\"cost\">%f</data>", v.From.id, v.To.id, v.Cost)
+			if routeSteps[Link[R]{v.From, v.To}] {
+				ew.printf("<data key=\"route\">true</data>")
+			}
+			ew.printf("</edge>\n")
+		}
+	}
+	ew.printf("  </graph>\n")
+	ew.printf("</graphml>\n")
+	return ew.err
+}
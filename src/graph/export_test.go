@@ -0,0 +1,136 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// town is a located record.
+type town struct {
+	name     string
+	lat, lon float64
+}
+
+func (t *town) String() string {
+	return t.name
+}
+
+func (t *town) ToLatLonDegrees() (lat, lon float64) {
+	return t.lat, t.lon
+}
+
+type anyTownPath struct{}
+
+func (anyTownPath) TraverseStateHelper(v *Vertex[*town]) (anyTownPath, bool) {
+	return anyTownPath{}, true
+}
+
+func exampleTowns() (*Graph[*town, anyTownPath], []*Node[*town]) {
+	g := NewGraph[*town, anyTownPath]()
+	nodes := []*Node[*town]{
+		g.NewNode(&town{"Ayr", 55.46, -4.63}),
+		g.NewNode(&town{"Bo'ness", 56.02, -3.6}),
+		g.NewNode(&town{"\"Crail\" & <Elie>", 56.26, -2.63}),
+	}
+	g.ConnectBi(nodes[0], nodes[1], 80.0)
+	g.ConnectBi(nodes[1], nodes[2], 60.0)
+	g.ConnectUni(nodes[0], nodes[2], 150.0)
+	return g, nodes
+}
+
+func TestWriteDOT(t *testing.T) {
+	g, nodes := exampleTowns()
+	var out bytes.Buffer
+	if err := g.WriteDOT(&out, "towns", []*Node[*town]{nodes[0], nodes[1], nodes[2]}); err != nil {
+		t.Fatal(err)
+	}
+
+	dot := out.String()
+	for _, expected := range []string{
+		"digraph \"towns\" {\n",
+		"n0 [label=\"Ayr\", pos=\"-4.630000,55.460000!\", color=red];",
+		"n2 [label=\"\\\"Crail\\\" & <Elie>\", pos=\"-2.630000,56.260000!\", color=red];",
+		"n0 -> n1 [label=\"80.000\", color=red, penwidth=3];",
+		"n1 -> n0 [label=\"80.000\"];",
+		"n0 -> n2 [label=\"150.000\"];",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("DOT output lacks %q:\n%s", expected, dot)
+		}
+	}
+	if strings.Count(dot, "->") != 5 {
+		t.Errorf("DOT output should have 5 edges:\n%s", dot)
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	g, nodes := exampleTowns()
+	var out bytes.Buffer
+	if err := g.WriteGraphML(&out, "towns", []*Node[*town]{nodes[0], nodes[2]}); err != nil {
+		t.Fatal(err)
+	}
+
+	type data struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+	var doc struct {
+		Graph struct {
+			Nodes []struct {
+				Id   string `xml:"id,attr"`
+				Data []data `xml:"data"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+				Data   []data `xml:"data"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	if err := xml.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("GraphML output is not well formed: %s\n%s", err, out.String())
+	}
+
+	if len(doc.Graph.Nodes) != 3 || len(doc.Graph.Edges) != 5 {
+		t.Fatalf("expected 3 nodes and 5 edges, found %d and %d", len(doc.Graph.Nodes), len(doc.Graph.Edges))
+	}
+	if label := doc.Graph.Nodes[2].Data[0].Value; label != nodes[2].Record.name {
+		t.Errorf("label %q did not survive escaping", label)
+	}
+	onRoute := 0
+	for _, e := range doc.Graph.Edges {
+		for _, d := range e.Data {
+			if d.Key == "route" {
+				onRoute++
+				if e.Source != "n0" || e.Target != "n2" {
+					t.Errorf("edge %s to %s marked on route", e.Source, e.Target)
+				}
+			}
+		}
+	}
+	if onRoute != 1 {
+		t.Errorf("expected one edge on route, found %d", onRoute)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestExportErrors(t *testing.T) {
+	g, _ := exampleTowns()
+	if err := g.WriteDOT(failingWriter{}, "towns", nil); err == nil {
+		t.Errorf("DOT write error not returned")
+	}
+	if err := g.WriteGraphML(failingWriter{}, "towns", nil); err == nil {
+		t.Errorf("GraphML write error not returned")
+	}
+	if err := g.WriteText(failingWriter{}); err == nil {
+		t.Errorf("text write error not returned")
+	}
+}
@@ -1,41 +1,28 @@
 package graph
 
 import (
-	"container/heap"
+	"context"
 	"fmt"
+	it "immutable_tree"
+	"log/slog"
+	"math"
+	"os"
 	sheap "slice_heap"
 )
 
-const (
-	BUILD_FLAG       = 1
-	TRAVERSE_FLAG    = 2
-	PROGRESSIVE_FLAG = 4
-	DEBUG            = 0 // | PROGRESSIVE_FLAG // | TRAVERSE_FLAG // | BUILD_FLAG 
-)
-
 // VisitedList
 
-type VisitedList struct {
-	node *Node
-	next *VisitedList
-}
-
-func (l *VisitedList) HasVisited(n *Node) bool {
-	for ; l != nil; l = l.next {
-		if l.node == n {
-			return true
-		}
-	}
-
-	return false
+type VisitedList[R NodeRecord] struct {
+	node *Node[R]
+	next *VisitedList[R]
 }
 
-func (l *VisitedList) AddNode(n *Node) *VisitedList {
-	return &VisitedList{n, l}
+func (l *VisitedList[R]) AddNode(n *Node[R]) *VisitedList[R] {
+	return &VisitedList[R]{n, l}
 }
 
-func (l *VisitedList) MakeSlice() (result []*Node) {
-	result = make([]*Node, 0)
+func (l *VisitedList[R]) MakeSlice() (result []*Node[R]) {
+	result = make([]*Node[R], 0)
 
 	// copy values from list into slice
 	for l2 := l; l2 != nil; l2 = l2.next {
@@ -51,7 +38,7 @@ func (l *VisitedList) MakeSlice() (result []*Node) {
 	return
 }
 
-func (l *VisitedList) Print() {
+func (l *VisitedList[R]) Print() {
 	if l.next != nil {
 		l.next.Print()
 	}
@@ -60,22 +47,43 @@ func (l *VisitedList) Print() {
 
 // State
 
-type PrivateTraverseState interface {
-	// LessThan(other *PrivateTraverseState) bool
-	TraverseStateHelper(v *Vertex) (nextState PrivateTraverseState, ok bool)
+// TraverseState is what a Graph's traversals need of the private state the
+// caller carries along each path: the state after taking a vertex, or not
+// ok if the vertex may not be taken.
+type TraverseState[R NodeRecord, S any] interface {
+	TraverseStateHelper(v *Vertex[R]) (nextState S, ok bool)
+}
+
+// CostingTraverseState is implemented by private states that price vertices
+// themselves (e.g., by time rather than distance) instead of using the fixed
+// Cost recorded on each Vertex.
+type CostingTraverseState[R NodeRecord] interface {
+	VertexCost(v *Vertex[R]) float64
+}
+
+// DominatingTraverseState is implemented by private states that carry
+// resources (e.g., fuel) which can make a costlier arrival at a node more
+// useful than a cheaper one. Such states are only discarded when a state
+// at the same node that costs no more dominates them.
+type DominatingTraverseState[S any] interface {
+	Dominates(other S) bool
 }
 
-type PublicTraverseState struct {
+type PublicTraverseState[R NodeRecord, S TraverseState[R, S]] struct {
 	totalCost    float64
-	node         *Node
-	visited      *VisitedList
-	privateState PrivateTraverseState
+	sequence     int // order pushed, so equal costs pop first in, first out
+	node         *Node[R]
+	visited      *VisitedList[R] // the path in order
+	before       *it.Tree        // the nodes before this one when needed to rule out cycles
+	privateState S
+	dead         bool // dominated by a state pushed since; see keptStates
 }
 
-func PublicStateLessThan(d1, d2 interface{}) bool {
-	pubState1 := d1.(*PublicTraverseState)
-	pubState2 := d2.(*PublicTraverseState)
-	return pubState1.totalCost < pubState2.totalCost
+func PublicStateLessThan[R NodeRecord, S TraverseState[R, S]](pubState1, pubState2 *PublicTraverseState[R, S]) bool {
+	if pubState1.totalCost != pubState2.totalCost {
+		return pubState1.totalCost < pubState2.totalCost
+	}
+	return pubState1.sequence < pubState2.sequence
 }
 
 // Node and NodeDescription and graph
@@ -84,110 +92,727 @@ type NodeRecord interface {
 	String() string
 }
 
-type Node struct {
-	Record   NodeRecord
-	vertices []*Vertex
+// Untyped graphs, whose records and private states are only known by their
+// interfaces, are what this package offered before it took type parameters.
+// Code written for them can move to these names and then to a typed Graph
+// at its own pace.
+
+type PrivateTraverseState interface {
+	TraverseStateHelper(v *Vertex[NodeRecord]) (nextState PrivateTraverseState, ok bool)
+}
+
+type (
+	UntypedGraph  = Graph[NodeRecord, PrivateTraverseState]
+	UntypedNode   = Node[NodeRecord]
+	UntypedVertex = Vertex[NodeRecord]
+)
+
+func NewUntypedGraph() *UntypedGraph {
+	return NewGraph[NodeRecord, PrivateTraverseState]()
+}
+
+// A Node's vertices are held one of two ways: while the node is being built
+// or changed each is allocated separately and listed in vertices; once the
+// graph is frozen they are held by value in edges, a stretch of an array
+// shared with other nodes. A changed node keeps its old edges until then.
+// Either way, the attributes of its vertices are held beside them.
+type Node[R NodeRecord] struct {
+	Record     R
+	id         int // index in the graph's nodes
+	vertices   []*Vertex[R]
+	edges      []Vertex[R]
+	attributes []float64 // the graph's number for each vertex, in their order
+}
+
+// Id returns the node's index in its graph, from 0 up to one less than the
+// number of nodes. Removing nodes renumbers those after them.
+func (n *Node[R]) Id() int {
+	return n.id
+}
+
+// degree returns the number of vertices leaving the node.
+func (n *Node[R]) degree() int {
+	if n.vertices != nil {
+		return len(n.vertices)
+	}
+	return len(n.edges)
 }
 
-type Vertex struct {
-	From, To *Node
+// vertex returns the i'th vertex leaving the node.
+func (n *Node[R]) vertex(i int) *Vertex[R] {
+	if n.vertices != nil {
+		return n.vertices[i]
+	}
+	return &n.edges[i]
+}
+
+// CompareTo orders nodes by id, so that they can be kept in an
+// immutable_tree.
+func (n *Node[R]) CompareTo(other it.Comparable) int {
+	return n.id - other.(*Node[R]).id
+}
+
+func (n *Node[R]) String() string {
+	return n.Record.String()
+}
+
+// A Vertex's attributes are further costs, numbered however the graph's
+// user likes, for traversals by several criteria; see Attribute. The node
+// it leaves holds them, so that a vertex costs no more for a graph having
+// them.
+type Vertex[R NodeRecord] struct {
+	From, To *Node[R]
 	Cost     float64
+	index    int32 // among the vertices leaving From
+}
+
+// Attributes returns the vertex's attributes, as many as its graph gives
+// each vertex, which are not to be changed.
+func (v *Vertex[R]) Attributes() []float64 {
+	n := v.From
+	if len(n.attributes) == 0 {
+		return nil
+	}
+	count := len(n.attributes) / n.degree()
+	start := int(v.index) * count
+	return n.attributes[start : start+count : start+count]
+}
+
+// VertexTo returns the cheapest vertex from n to the given node, or nil if
+// they are not connected.
+func (n *Node[R]) VertexTo(to *Node[R]) (result *Vertex[R]) {
+	for i := 0; i < n.degree(); i++ {
+		if v := n.vertex(i); v.To == to && (result == nil || v.Cost < result.Cost) {
+			result = v
+		}
+	}
+	return
+}
+
+// CostFor returns the cost of traversing the vertex from the given private
+// state, deferring to the state when it implements CostingTraverseState.
+func (v *Vertex[R]) CostFor(state any) float64 {
+	if costing, ok := state.(CostingTraverseState[R]); ok {
+		return costing.VertexCost(v)
+	}
+	return v.Cost
 }
 
 // Graph
 
-type Graph struct {
-	nodes    []*Node
-	vertices []*Vertex
+// A Graph is built by one goroutine. Once built, any number of goroutines
+// may search it at the same time, provided none changes it meanwhile.
+//
+// A built graph can be frozen into a compressed layout: every vertex is
+// held by value in an array, each node's together, ordered by the id of the
+// node they leave. That saves a pointer and an allocation per vertex and
+// keeps a node's vertices together.
+// A frozen graph also lists the vertices arriving at each node, for
+// searches that work backward. Changing a frozen graph thaws only the nodes
+// whose vertices change, and freezing it again packs only those, into an
+// array of their own, so that a few changes to a large graph are cheap.
+type Graph[R NodeRecord, S TraverseState[R, S]] struct {
+	nodes          []*Node[R]
+	thawed         []*Node[R]     // the nodes added or changed since last frozen
+	incoming       [][]*Vertex[R] // by node id, once first frozen; see Freeze
+	attributeCount int            // of each vertex
 }
 
-func NewGraph() *Graph {
-	return &Graph{make([]*Node, 0), make([]*Vertex, 0)}
+func NewGraph[R NodeRecord, S TraverseState[R, S]]() *Graph[R, S] {
+	return NewGraphWithAttributes[R, S](0)
 }
 
-func (g *Graph) NewNode(record NodeRecord) *Node {
-	n := &Node{record, make([]*Vertex, 0)}
+// NewGraphWithAttributes returns a graph whose vertices each have the given
+// number of attributes, which are 0 unless given when connecting nodes.
+func NewGraphWithAttributes[R NodeRecord, S TraverseState[R, S]](count int) *Graph[R, S] {
+	return &Graph[R, S]{make([]*Node[R], 0), make([]*Node[R], 0), nil, count}
+}
+
+// Nodes returns the graph's nodes in id order.
+func (g *Graph[R, S]) Nodes() []*Node[R] {
+	return g.nodes
+}
+
+// Has reports whether a node is in the graph, rather than removed from it.
+func (g *Graph[R, S]) Has(n *Node[R]) bool {
+	return n.id < len(g.nodes) && g.nodes[n.id] == n
+}
+
+func (g *Graph[R, S]) NewNode(record R) *Node[R] {
+	n := &Node[R]{record, len(g.nodes), make([]*Vertex[R], 0), nil, nil}
 	g.nodes = append(g.nodes, n)
+	g.thawed = append(g.thawed, n)
+	if g.incoming != nil {
+		g.incoming = append(g.incoming, make([]*Vertex[R], 0))
+	}
 	return n
 }
 
-func (g *Graph) ConnectUni(from, to *Node, cost float64) {
-	v := &Vertex{from, to, cost}
-	g.vertices = append(g.vertices, v)
+// Thawed returns the nodes added, or whose vertices changed, since the graph
+// was last frozen, which are all of them if it never was.
+func (g *Graph[R, S]) Thawed() []*Node[R] {
+	return append([]*Node[R](nil), g.thawed...)
+}
+
+// Freeze packs the vertices of the nodes thawed into the compressed layout,
+// keeping each node's vertices in the order they were added, and brings the
+// vertices arriving at the nodes they leave or reach up to date.
+func (g *Graph[R, S]) Freeze() {
+	if g.IsFrozen() {
+		return
+	}
+
+	// the nodes whose arrivals change, by the rows both before and after
+	stale := make([]bool, len(g.nodes))
+	count := 0
+	for _, n := range g.thawed {
+		for i := range n.edges {
+			if to := n.edges[i].To; g.Has(to) {
+				stale[to.id] = true
+			}
+		}
+		for _, v := range n.vertices {
+			stale[v.To.id] = true
+		}
+		count += len(n.vertices)
+	}
+
+	edges := make([]Vertex[R], 0, count)
+	attributes := make([]float64, 0, count*g.attributeCount)
+	for _, n := range g.thawed {
+		start := len(edges)
+		for _, v := range n.vertices {
+			edges = append(edges, *v)
+		}
+		n.edges = edges[start:len(edges):len(edges)]
+		n.vertices = nil
+
+		start = len(attributes)
+		attributes = append(attributes, n.attributes...)
+		n.attributes = attributes[start:len(attributes):len(attributes)]
+	}
+
+	if g.incoming == nil {
+		g.incoming = g.arrivals()
+	} else {
+		g.rearrive(stale)
+	}
+	g.thawed = g.thawed[:0]
+}
+
+// rearrive brings up to date the vertices arriving at the stale nodes, those
+// from the nodes just packed having moved.
+func (g *Graph[R, S]) rearrive(stale []bool) {
+	packed := make(map[*Node[R]]bool, len(g.thawed))
+	for _, n := range g.thawed {
+		packed[n] = true
+	}
+	for id, isStale := range stale {
+		if !isStale {
+			continue
+		}
+		kept := g.incoming[id][:0]
+		for _, v := range g.incoming[id] {
+			if !packed[v.From] {
+				kept = append(kept, v)
+			}
+		}
+		g.incoming[id] = kept
+	}
+	for _, n := range g.thawed {
+		for i := range n.edges {
+			v := &n.edges[i]
+			g.incoming[v.To.id] = append(g.incoming[v.To.id], v)
+		}
+	}
+}
+
+// IsFrozen reports whether the graph is in the compressed layout.
+func (g *Graph[R, S]) IsFrozen() bool {
+	return g.incoming != nil && len(g.thawed) == 0
+}
+
+// thaw readies a node of a frozen graph to change, pointing to its packed
+// vertices, which stay where they are until the graph is frozen again.
+func (g *Graph[R, S]) thaw(n *Node[R]) {
+	if n.vertices != nil {
+		return
+	}
+
+	n.vertices = make([]*Vertex[R], len(n.edges))
+	for i := range n.edges {
+		n.vertices[i] = &n.edges[i]
+	}
+	g.thawed = append(g.thawed, n)
+}
+
+// arrivals lists the vertices arriving at each node, by node id.
+func (g *Graph[R, S]) arrivals() [][]*Vertex[R] {
+	counts := make([]int, len(g.nodes))
+	for _, n := range g.nodes {
+		for i := 0; i < n.degree(); i++ {
+			counts[n.vertex(i).To.id]++
+		}
+	}
+	incoming := make([][]*Vertex[R], len(g.nodes))
+	for id, count := range counts {
+		incoming[id] = make([]*Vertex[R], 0, count)
+	}
+	for _, n := range g.nodes {
+		for i := 0; i < n.degree(); i++ {
+			v := n.vertex(i)
+			incoming[v.To.id] = append(incoming[v.To.id], v)
+		}
+	}
+	return incoming
+}
+
+func (g *Graph[R, S]) ConnectUni(from, to *Node[R], cost float64) {
+	g.ConnectUniWith(from, to, cost, nil)
+}
+
+// ConnectUniWith connects two nodes by a vertex with attributes as well as
+// a cost, no more of them than the graph gives each vertex.
+func (g *Graph[R, S]) ConnectUniWith(from, to *Node[R], cost float64, attributes []float64) {
+	if len(attributes) > g.attributeCount {
+		panic(fmt.Sprintf("%d attributes for a graph with %d", len(attributes), g.attributeCount))
+	}
+	g.thaw(from)
+	v := &Vertex[R]{from, to, cost, int32(len(from.vertices))}
 	from.vertices = append(from.vertices, v)
-	if DEBUG&BUILD_FLAG != 0 {
-		fmt.Printf("Connecting %s to %s at cost %f.\n", from.Record.String(), to.Record.String(), cost)
+	if g.attributeCount > 0 {
+		from.attributes = append(from.attributes, attributes...)
+		from.attributes = append(from.attributes, make([]float64, g.attributeCount-len(attributes))...)
+	}
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		slog.Debug("connect", "from", from.Record, "to", to.Record, "cost", cost)
 	}
 }
 
-func (g *Graph) ConnectBi(n1, n2 *Node, cost float64) {
+func (g *Graph[R, S]) ConnectBi(n1, n2 *Node[R], cost float64) {
 	g.ConnectUni(n1, n2, cost)
 	g.ConnectUni(n2, n1, cost)
 }
 
-func (g *Graph) Traverse(privateState PrivateTraverseState, from, to *Node) (path []*Node, totalCost float64, ok bool) {
-	seen := make(map[*Node]bool)
+// ConnectBiWith connects two nodes both ways by vertices sharing the same
+// cost and attributes.
+func (g *Graph[R, S]) ConnectBiWith(n1, n2 *Node[R], cost float64, attributes []float64) {
+	g.ConnectUniWith(n1, n2, cost, attributes)
+	g.ConnectUniWith(n2, n1, cost, attributes)
+}
 
-	state := &PublicTraverseState{0.0, from, &VisitedList{from, nil}, privateState}
+// RemoveNodes takes nodes out of the graph along with every vertex to or
+// from them. Once the graph has been frozen, only the nodes with vertices to
+// those removed are thawed.
+func (g *Graph[R, S]) RemoveNodes(doomed ...*Node[R]) {
+	isDoomed := make(map[*Node[R]]bool)
+	for _, n := range doomed {
+		isDoomed[n] = true
+	}
 
-	sh := sheap.NewSliceHeap(PublicStateLessThan)
-	heap.Init(sh)
-	heap.Push(sh, state)
+	if g.incoming != nil {
+		for _, n := range doomed {
+			for _, v := range g.incoming[n.id] {
+				if !isDoomed[v.From] {
+					g.thaw(v.From)
+				}
+			}
+			// the vertices leaving n no longer arrive anywhere
+			for i := 0; i < n.degree(); i++ {
+				if to := n.vertex(i).To; !isDoomed[to] {
+					kept := g.incoming[to.id][:0]
+					for _, v := range g.incoming[to.id] {
+						if !isDoomed[v.From] {
+							kept = append(kept, v)
+						}
+					}
+					g.incoming[to.id] = kept
+				}
+			}
+		}
+	}
 
-	for !sh.IsEmpty() {
-		state = heap.Pop(sh).(*PublicTraverseState)
-		if _, found := seen[state.node]; found {
+	// every node with a vertex to those removed is now thawed, including
+	// those given one since the graph was last frozen
+	thawed := g.thawed[:0]
+	for _, n := range g.thawed {
+		if isDoomed[n] {
 			continue
 		}
-		seen[state.node] = true
+		kept, attributes := n.vertices[:0], n.attributes[:0]
+		for _, v := range n.vertices {
+			if !isDoomed[v.To] {
+				start := int(v.index) * g.attributeCount
+				attributes = append(attributes, n.attributes[start:start+g.attributeCount]...)
+				v.index = int32(len(kept))
+				kept = append(kept, v)
+			}
+		}
+		n.vertices, n.attributes = kept, attributes
+		thawed = append(thawed, n)
+	}
+	g.thawed = thawed
 
-		if DEBUG&PROGRESSIVE_FLAG != 0 {
-			fmt.Printf("%f : %s\n", state.totalCost, state.node.Record)
+	nodes := g.nodes[:0]
+	for _, n := range g.nodes {
+		if !isDoomed[n] {
+			if g.incoming != nil {
+				g.incoming[len(nodes)] = g.incoming[n.id]
+			}
+			n.id = len(nodes)
+			nodes = append(nodes, n)
 		}
+	}
+	if g.incoming != nil {
+		g.incoming = g.incoming[:len(nodes)]
+	}
+	g.nodes = nodes
 
-		if state.node == to {
-			return state.visited.MakeSlice(), state.totalCost, true
+	for _, n := range doomed {
+		n.vertices, n.edges, n.attributes = nil, nil, nil
+	}
+}
+
+// disconnect takes away every vertex leaving a node.
+func (g *Graph[R, S]) disconnect(n *Node[R]) {
+	g.thaw(n)
+	n.vertices, n.attributes = n.vertices[:0], n.attributes[:0]
+}
+
+// Traverse finds the cheapest path between two nodes that the private state
+// allows. All of its working state is its own, so concurrent calls are safe
+// as long as the graph is not changed and the private states passed in are
+// themselves safe to use concurrently. A private state implementing
+// ObservedTraverseState has each step told to its observer.
+func (g *Graph[R, S]) Traverse(privateState S, from, to *Node[R]) (path []*Node[R], totalCost float64, ok bool) {
+	path, totalCost, ok, _ = g.TraverseContext(context.Background(), privateState, from, to, 0)
+	return
+}
+
+// TraverseContext is Traverse giving up, with a *TraverseError, once the
+// context is done or more than maxStates states have been pushed (0 for no
+// limit).
+func (g *Graph[R, S]) TraverseContext(ctx context.Context, privateState S, from, to *Node[R], maxStates int) (path []*Node[R], totalCost float64, ok bool, err error) {
+	return g.TraverseMulti(ctx, privateState, []Endpoint[R]{{from, 0.0}}, []Endpoint[R]{{to, 0.0}}, maxStates)
+}
+
+// An Endpoint is a node a traversal can start or finish at, with a cost
+// added to paths starting or finishing there, e.g., for getting to it.
+type Endpoint[R NodeRecord] struct {
+	Node   *Node[R]
+	Offset float64
+}
+
+// TraverseMulti is TraverseContext finding the cheapest path from any of the
+// sources to any of the targets, counting the offsets of both ends in its
+// cost. The path runs from the source to the target of the best pair.
+// Having given up, it still returns the cheapest path found, if any, which
+// may not be the cheapest there is.
+func (g *Graph[R, S]) TraverseMulti(ctx context.Context, privateState S, sources, targets []Endpoint[R], maxStates int) (path []*Node[R], totalCost float64, ok bool, err error) {
+	observer := observerFor[R](privateState)
+	observer.Started(endpointNodes(sources), endpointNodes(targets))
+	defer func() { observer.Finished(ok) }()
+
+	// Reaching a target is final only if no other target costs less to
+	// finish at; otherwise the search goes on until nothing left can beat
+	// the best reached.
+	isTarget := make([]bool, len(g.nodes))
+	targetOffsets := make([]float64, len(g.nodes))
+	leastOffset := math.Inf(1)
+	for _, t := range targets {
+		if !isTarget[t.Node.id] || t.Offset < targetOffsets[t.Node.id] {
+			targetOffsets[t.Node.id] = t.Offset
 		}
+		isTarget[t.Node.id] = true
+		leastOffset = math.Min(leastOffset, t.Offset)
+	}
+	var best *PublicTraverseState[R, S]
+	totalCost = math.Inf(1)
+
+	// whether each node has been expanded
+	seen := make([]bool, len(g.nodes))
+
+	// Without dominance a node is expanded at most once, and every node on
+	// a path has already been expanded, so seen alone rules out cycles.
+	// Otherwise each path carries a persistent set of its nodes, extended
+	// only as states are expanded since most are never popped.
+	var before *it.Tree
+	var kept keptStates[R, S]
+	_, dominating := any(privateState).(DominatingTraverseState[S])
+	if dominating {
+		before = it.NewTree()
+		kept = make(keptStates[R, S], len(g.nodes))
+	}
+
+	sh := sheap.NewSliceHeap(PublicStateLessThan[R, S])
+	sequence := 0
+	for _, source := range sources {
+		if dominating && kept.dominated(source.Node, source.Offset, privateState) {
+			continue
+		}
+		state := &PublicTraverseState[R, S]{source.Offset, sequence, source.Node, &VisitedList[R]{source.Node, nil}, before, privateState, false}
+		if dominating {
+			kept.keep(state)
+		}
+		sh.PushItem(state)
+		sequence++
+	}
+	b := &budget{ctx, maxStates, len(sources)}
 
-		for _, vertex := range state.node.vertices {
-			if DEBUG&TRAVERSE_FLAG != 0 {
-				fmt.Printf("Considering %s to %s ... ", state.node.Record, vertex.To.Record)
+	for !sh.IsEmpty() && sh.Peek().totalCost+leastOffset < totalCost {
+		// states are popped in cost order, so none left costs less
+		if err = b.spent(sh.Peek().totalCost + leastOffset); err != nil {
+			break
+		}
+		state := sh.PopItem()
+		if state.dead || (!dominating && seen[state.node.id]) {
+			observer.Popped(state.node, state.totalCost, false)
+			continue
+		}
+		seen[state.node.id] = true
+		observer.Popped(state.node, state.totalCost, true)
+
+		if isTarget[state.node.id] && state.totalCost+targetOffsets[state.node.id] < totalCost {
+			best, totalCost = state, state.totalCost+targetOffsets[state.node.id]
+			if targetOffsets[state.node.id] == leastOffset {
+				break
 			}
-			totalCost := state.totalCost + vertex.Cost
+		}
+
+		var onPath *it.Tree
+		if state.before != nil {
+			onPath = state.before.AddValue(state.node)
+		}
+
+		for i := 0; i < state.node.degree(); i++ {
+			vertex := state.node.vertex(i)
+			totalCost := state.totalCost + vertex.CostFor(state.privateState)
 			nextNode := vertex.To
-			if state.visited.HasVisited(nextNode) {
-				if DEBUG&TRAVERSE_FLAG != 0 {
-					fmt.Println("already been there")
-				}
+			// only nodes already expanded can be on the path, and without
+			// dominance none of them is worth reaching again
+			if seen[nextNode.id] && (!dominating || onPath.HasValue(nextNode)) {
+				observer.Rejected(vertex, REJECT_VISITED)
 				continue
 			}
 			nextPrivateState, ok := (state.privateState).TraverseStateHelper(vertex)
 			if !ok {
-				if DEBUG&TRAVERSE_FLAG != 0 {
-					fmt.Println("rejected by calling code")
-				}
+				observer.Rejected(vertex, REJECT_STATE)
 				continue
 			}
-			nextPublicState := &PublicTraverseState{totalCost, nextNode, &VisitedList{nextNode, state.visited}, nextPrivateState}
-			heap.Push(sh, nextPublicState)
+			if dominating && kept.dominated(nextNode, totalCost, nextPrivateState) {
+				observer.Rejected(vertex, REJECT_DOMINATED)
+				continue
+			}
+			sequence++
+			nextPublicState := &PublicTraverseState[R, S]{totalCost, sequence, nextNode, &VisitedList[R]{nextNode, state.visited}, onPath, nextPrivateState, false}
+			if dominating {
+				kept.keep(nextPublicState)
+			}
+			sh.PushItem(nextPublicState)
+			b.states++
+			observer.Pushed(vertex, totalCost, sh.Len())
+		}
+	}
+
+	if best == nil {
+		return nil, 0.0, false, err
+	}
+	observer.Reached(best.node, totalCost)
+	return best.visited.MakeSlice(), totalCost, true, err
+}
 
-			if DEBUG&TRAVERSE_FLAG != 0 {
-				fmt.Printf("good w/ total cost of %f\n", totalCost)
+// keptStates keeps, at each node, the states pushed there that no other state
+// pushed there both dominates and costs no more than. Those it drops for a
+// state pushed later are marked dead, to be discarded when popped, so that
+// the heap need not be searched for them.
+type keptStates[R NodeRecord, S TraverseState[R, S]] [][]keptState[R, S]
+
+// keptState is a state with its private state boxed once, as many states
+// are checked against it.
+type keptState[R NodeRecord, S TraverseState[R, S]] struct {
+	state      *PublicTraverseState[R, S]
+	dominating DominatingTraverseState[S]
+}
+
+// dominated reports whether a state kept at a node is as good as a private
+// state reaching it at a cost, checked before a state is made for it.
+func (ls keptStates[R, S]) dominated(n *Node[R], totalCost float64, privateState S) bool {
+	for _, other := range ls[n.id] {
+		if other.state.totalCost <= totalCost && other.dominating.Dominates(privateState) {
+			return true
+		}
+	}
+	return false
+}
+
+// keep adds a state not dominated to those kept at its node, dropping those
+// it dominates.
+func (ls keptStates[R, S]) keep(state *PublicTraverseState[R, S]) {
+	kept := ls[state.node.id]
+	dominating := any(state.privateState).(DominatingTraverseState[S])
+	live := kept[:0]
+	for _, other := range kept {
+		if state.totalCost <= other.state.totalCost && dominating.Dominates(other.state.privateState) {
+			other.state.dead = true
+		} else {
+			live = append(live, other)
+		}
+	}
+	ls[state.node.id] = append(live, keptState[R, S]{state, dominating})
+}
+
+// Hop is the cheapest way between two stops that passes through no other
+// stop.
+type Hop[R NodeRecord] struct {
+	From, To *Node[R]
+	Cost     float64
+	visited  *VisitedList[R] // the way from From to To
+}
+
+// Nodes returns the nodes the hop passes through, from From to To.
+func (h Hop[R]) Nodes() []*Node[R] {
+	return h.visited.MakeSlice()
+}
+
+type hopState[R NodeRecord] struct {
+	node     *Node[R]
+	cost     float64
+	sequence int
+	visited  *VisitedList[R]
+}
+
+func hopStateLessThan[R NodeRecord](s1, s2 *hopState[R]) bool {
+	if s1.cost != s2.cost {
+		return s1.cost < s2.cost
+	}
+	return s1.sequence < s2.sequence
+}
+
+// Hops returns the cheapest hop from a node to each stop it can reach
+// without passing through another, using only vertices allowed (all, if
+// allow is nil).
+func (g *Graph[R, S]) Hops(from *Node[R], isStop func(*Node[R]) bool, allow func(*Vertex[R]) bool) (hops []Hop[R]) {
+	return g.hopsWithin(from, isStop, allow, nil, math.Inf(1))
+}
+
+// costBy returns a vertex's cost by vertexCost or, if that is nil, its Cost.
+func costBy[R NodeRecord](vertexCost func(*Vertex[R]) float64, v *Vertex[R]) float64 {
+	if vertexCost == nil {
+		return v.Cost
+	}
+	return vertexCost(v)
+}
+
+// hopsWithin is Hops for only the hops costing no more than maxCost, by
+// vertexCost as costBy has it.
+func (g *Graph[R, S]) hopsWithin(from *Node[R], isStop func(*Node[R]) bool, allow func(*Vertex[R]) bool, vertexCost func(*Vertex[R]) float64, maxCost float64) (hops []Hop[R]) {
+	hops = make([]Hop[R], 0)
+	seen := make([]bool, len(g.nodes))
+	// the cheapest way to each node pushed so far, as dense graphs would
+	// otherwise fill the heap with costlier ways to the same nodes
+	pushed := make([]float64, len(g.nodes))
+	for i := range pushed {
+		pushed[i] = math.Inf(1)
+	}
+
+	sh := sheap.NewSliceHeap(hopStateLessThan[R])
+	sequence := 0
+	sh.PushItem(&hopState[R]{from, 0.0, sequence, &VisitedList[R]{from, nil}})
+
+	for !sh.IsEmpty() {
+		state := sh.PopItem()
+		if seen[state.node.id] {
+			continue
+		}
+		seen[state.node.id] = true
+
+		if state.node != from && isStop(state.node) {
+			hops = append(hops, Hop[R]{from, state.node, state.cost, state.visited})
+			continue
+		}
+
+		for i := 0; i < state.node.degree(); i++ {
+			vertex := state.node.vertex(i)
+			if cost := state.cost + costBy(vertexCost, vertex); !seen[vertex.To.id] && cost <= maxCost && cost < pushed[vertex.To.id] && (allow == nil || allow(vertex)) {
+				pushed[vertex.To.id] = cost
+				sequence++
+				sh.PushItem(&hopState[R]{vertex.To, cost, sequence, state.visited.AddNode(vertex.To)})
 			}
 		}
 	}
 
-	return nil, 0.0, false
+	return
 }
 
-func (g *Graph) Display() {
-	for _, n := range g.nodes {
-		fmt.Printf("%s:\n", n.Record)
-		for _, v := range n.vertices {
-			fmt.Printf("    %s @ %f\n", v.To.Record, v.Cost)
-			if v.From != n {
-				panic("non-matching node/vertex")
+type minimaxState[R NodeRecord] struct {
+	node     *Node[R]
+	cost     float64 // the costliest hop so far, or the total
+	sequence int
+	visited  *VisitedList[R]
+}
+
+func minimaxStateLessThan[R NodeRecord](s1, s2 *minimaxState[R]) bool {
+	if s1.cost != s2.cost {
+		return s1.cost < s2.cost
+	}
+	return s1.sequence < s2.sequence
+}
+
+// MinimaxPath finds the stops to make between two nodes so that the
+// costliest hop between consecutive stops is as cheap as possible, e.g.,
+// the smallest range an aircraft needs to make a trip. Ties are broken by
+// total cost. Both ends must be stops.
+//
+// The way to a stop with the cheapest costliest hop need not start the
+// cheapest way on, since a later hop may be costlier than any so far on
+// either. So a first search finds the bottleneck and a second the
+// cheapest total over hops no costlier than it.
+func (g *Graph[R, S]) MinimaxPath(from, to *Node[R], isStop func(*Node[R]) bool, allow func(*Vertex[R]) bool) (stops []*Node[R], bottleneck float64, ok bool) {
+	return g.MinimaxPathWithin(from, to, isStop, allow, nil, math.Inf(1))
+}
+
+// MinimaxPathWithin is MinimaxPath using only hops costing no more than
+// maxHop, costing each vertex by vertexCost as costBy has it.
+func (g *Graph[R, S]) MinimaxPathWithin(from, to *Node[R], isStop func(*Node[R]) bool, allow func(*Vertex[R]) bool, vertexCost func(*Vertex[R]) float64, maxHop float64) (stops []*Node[R], bottleneck float64, ok bool) {
+	if _, bottleneck, ok = g.searchStops(from, to, isStop, allow, vertexCost, maxHop, math.Max); !ok {
+		return nil, 0.0, false
+	}
+	stops, _, ok = g.searchStops(from, to, isStop, allow, vertexCost, bottleneck, func(total, hop float64) float64 { return total + hop })
+	return stops, bottleneck, ok
+}
+
+// searchStops finds the way between two stops over hops costing no more
+// than maxHop for which combining the hops' costs in turn gives the least
+// cost, which needs that combining never makes a cost cheaper.
+func (g *Graph[R, S]) searchStops(from, to *Node[R], isStop func(*Node[R]) bool, allow func(*Vertex[R]) bool, vertexCost func(*Vertex[R]) float64, maxHop float64, combine func(cost, hop float64) float64) (stops []*Node[R], cost float64, ok bool) {
+	seen := make([]bool, len(g.nodes))
+	sh := sheap.NewSliceHeap(minimaxStateLessThan[R])
+	sequence := 0
+	sh.PushItem(&minimaxState[R]{from, 0.0, sequence, &VisitedList[R]{from, nil}})
+
+	for !sh.IsEmpty() {
+		state := sh.PopItem()
+		if seen[state.node.id] {
+			continue
+		}
+		seen[state.node.id] = true
+
+		if state.node == to {
+			return state.visited.MakeSlice(), state.cost, true
+		}
+
+		for _, hop := range g.hopsWithin(state.node, isStop, allow, vertexCost, maxHop) {
+			if !seen[hop.To.id] {
+				sequence++
+				sh.PushItem(&minimaxState[R]{hop.To, combine(state.cost, hop.Cost), sequence, state.visited.AddNode(hop.To)})
 			}
 		}
 	}
+
+	return nil, 0.0, false
+}
+
+// Display prints the graph as WriteText writes it.
+func (g *Graph[R, S]) Display() {
+	g.WriteText(os.Stdout)
 }
@@ -0,0 +1,503 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+type anyPath struct{}
+
+func (anyPath) TraverseStateHelper(v *Vertex[name]) (anyPath, bool) {
+	return anyPath{}, true
+}
+
+// randomGraph links n nodes with random costs, mostly to near neighbours.
+func randomGraph[S TraverseState[name, S]](r *rand.Rand, n int) (*Graph[name, S], []*Node[name]) {
+	g := NewGraph[name, S]()
+	nodes := make([]*Node[name], n)
+	for i := range nodes {
+		nodes[i] = g.NewNode(name(fmt.Sprintf("n%d", i)))
+	}
+	for i := range nodes {
+		for k := 0; k < 3; k++ {
+			j := (i + 1 + r.Intn(5)) % n
+			g.ConnectBi(nodes[i], nodes[j], float64(1+r.Intn(20)))
+		}
+	}
+	return g, nodes
+}
+
+func TestConcurrentTraverse(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	g, nodes := randomGraph[anyPath](r, 200)
+
+	type query struct {
+		from, to *Node[name]
+		cost     float64
+	}
+	queries := make([]query, 100)
+	for i := range queries {
+		queries[i].from, queries[i].to = nodes[r.Intn(len(nodes))], nodes[r.Intn(len(nodes))]
+		_, queries[i].cost, _ = g.Traverse(anyPath{}, queries[i].from, queries[i].to)
+	}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := worker; i < len(queries); i += 8 {
+				q := queries[i]
+				if _, cost, _ := g.Traverse(anyPath{}, q.from, q.to); cost != q.cost {
+					t.Errorf("%s to %s costs %f concurrently but %f alone", q.from.Record, q.to.Record, cost, q.cost)
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+}
+
+// neverDominated keeps every arrival alive, so only the path's own record
+// of its nodes keeps it from going round in circles.
+type neverDominated struct{}
+
+func (neverDominated) TraverseStateHelper(v *Vertex[name]) (neverDominated, bool) {
+	return neverDominated{}, true
+}
+
+func (neverDominated) Dominates(other neverDominated) bool {
+	return false
+}
+
+func TestTraverseWithoutCycles(t *testing.T) {
+	// the same graph twice, to be searched with each kind of state
+	g, nodes := randomGraph[anyPath](rand.New(rand.NewSource(5)), 12)
+	g2, nodes2 := randomGraph[neverDominated](rand.New(rand.NewSource(5)), 12)
+	r := rand.New(rand.NewSource(6))
+	for trial := 0; trial < 20; trial++ {
+		i, j := r.Intn(len(nodes)), r.Intn(len(nodes))
+		from, to := nodes[i], nodes[j]
+		_, expected, _ := g.Traverse(anyPath{}, from, to)
+		path, cost, ok := g2.Traverse(neverDominated{}, nodes2[i], nodes2[j])
+		if !ok || cost != expected {
+			t.Errorf("%s to %s costs %f rather than %f", from.Record, to.Record, cost, expected)
+		}
+		onPath := make(map[*Node[name]]bool)
+		for _, n := range path {
+			if onPath[n] {
+				t.Errorf("path %v visits %s twice", path, n.Record)
+			}
+			onPath[n] = true
+		}
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	r := rand.New(rand.NewSource(6))
+	g, nodes := randomGraph[anyPath](r, 100)
+
+	type query struct {
+		from, to *Node[name]
+		cost     float64
+	}
+	queries := make([]query, 50)
+	for i := range queries {
+		queries[i].from, queries[i].to = nodes[r.Intn(len(nodes))], nodes[r.Intn(len(nodes))]
+		_, queries[i].cost, _ = g.Traverse(anyPath{}, queries[i].from, queries[i].to)
+	}
+
+	g.Freeze()
+	edges := 0
+	for _, n := range g.Nodes() {
+		edges += len(n.edges)
+	}
+	if !g.IsFrozen() || edges != 600 {
+		t.Fatalf("frozen graph has %d edges rather than 600", edges)
+	}
+	for _, q := range queries {
+		if _, cost, _ := g.Traverse(anyPath{}, q.from, q.to); cost != q.cost {
+			t.Errorf("%s to %s costs %f frozen but %f built", q.from.Record, q.to.Record, cost, q.cost)
+		}
+	}
+
+	// changing the graph thaws it
+	g.ConnectBi(nodes[0], nodes[99], 0.5)
+	if g.IsFrozen() {
+		t.Errorf("graph still frozen after a change")
+	}
+	if _, cost, _ := g.Traverse(anyPath{}, nodes[0], nodes[99]); cost != 0.5 {
+		t.Errorf("new vertex not used; cost %f", cost)
+	}
+
+	g.RemoveNodes(nodes[10], nodes[20])
+	for i, n := range g.Nodes() {
+		if n.Id() != i {
+			t.Fatalf("node %s has id %d at index %d", n.Record, n.Id(), i)
+		}
+	}
+	g.Freeze()
+	if _, cost, _ := g.Traverse(anyPath{}, nodes[0], nodes[99]); cost != 0.5 {
+		t.Errorf("refrozen graph lost the new vertex; cost %f", cost)
+	}
+}
+
+// TestRefreeze changes a frozen graph again and again and checks that each
+// time it is frozen its nodes' vertices, and those arriving at each node,
+// are those of the graph rebuilt from scratch.
+func TestRefreeze(t *testing.T) {
+	r := rand.New(rand.NewSource(10))
+	g, nodes := randomGraph[anyPath](r, 100)
+	g.Freeze()
+
+	for round := 0; round < 20; round++ {
+		for k := 0; k < 3; k++ {
+			n := g.NewNode(name(fmt.Sprintf("r%d.%d", round, k)))
+			g.ConnectBi(n, nodes[r.Intn(len(nodes))], float64(1+r.Intn(20)))
+			nodes = append(nodes, n)
+		}
+		g.ConnectUni(nodes[r.Intn(len(nodes))], nodes[r.Intn(len(nodes))], float64(1+r.Intn(20)))
+		doomed := nodes[r.Intn(len(nodes))]
+		g.RemoveNodes(doomed)
+		kept := nodes[:0]
+		for _, n := range nodes {
+			if n != doomed {
+				kept = append(kept, n)
+			}
+		}
+		nodes = kept
+		if g.IsFrozen() {
+			t.Fatalf("round %d: graph frozen after changes", round)
+		}
+		thawed := len(g.Thawed())
+		g.Freeze()
+		if thawed >= len(nodes)/2 {
+			t.Errorf("round %d: %d of %d nodes thawed", round, thawed, len(nodes))
+		}
+
+		fresh := NewGraph[name, anyPath]()
+		copies := make(map[*Node[name]]*Node[name])
+		for _, n := range g.Nodes() {
+			copies[n] = fresh.NewNode(n.Record)
+		}
+		for _, n := range g.Nodes() {
+			if !g.Has(n) || n.vertices != nil {
+				t.Fatalf("round %d: node %s not frozen in the graph", round, n.Record)
+			}
+			for i := 0; i < n.degree(); i++ {
+				v := n.vertex(i)
+				if !g.Has(v.To) {
+					t.Fatalf("round %d: %s leads to a removed node", round, n.Record)
+				}
+				fresh.ConnectUni(copies[n], copies[v.To], v.Cost)
+			}
+		}
+		fresh.Freeze()
+
+		for _, n := range g.Nodes() {
+			if len(g.incoming[n.id]) != len(fresh.incoming[copies[n].id]) {
+				t.Fatalf("round %d: %d vertices arrive at %s rather than %d", round, len(g.incoming[n.id]), n.Record, len(fresh.incoming[copies[n].id]))
+			}
+			arriving := make(map[*Vertex[name]]bool)
+			for _, v := range g.incoming[n.id] {
+				if v.To != n || v.From.VertexTo(n) == nil || arriving[v] {
+					t.Fatalf("round %d: vertex from %s arriving at %s is stale", round, v.From.Record, n.Record)
+				}
+				found := false
+				for i := 0; i < v.From.degree(); i++ {
+					found = found || v.From.vertex(i) == v
+				}
+				if !found {
+					t.Fatalf("round %d: vertex from %s arriving at %s is not %s's", round, v.From.Record, n.Record, v.From.Record)
+				}
+				arriving[v] = true
+			}
+		}
+	}
+}
+
+// rangeLeft limits the cost between stops, every third node, the way an
+// aircraft's range does. Searched backward it holds what can be spent
+// before the node reached rather than after it.
+type rangeLeft struct {
+	left     float64
+	backward bool
+}
+
+const RANGE_BETWEEN_STOPS = 30.0
+
+func (s rangeLeft) TraverseStateHelper(v *Vertex[name]) (rangeLeft, bool) {
+	next := v.To
+	if s.backward {
+		next = v.From
+	}
+	if v.Cost > s.left {
+		return s, false
+	}
+	if next.Id()%3 == 0 {
+		return rangeLeft{RANGE_BETWEEN_STOPS, s.backward}, true
+	}
+	return rangeLeft{s.left - v.Cost, s.backward}, true
+}
+
+func (s rangeLeft) Dominates(other rangeLeft) bool {
+	return s.left >= other.left
+}
+
+func (s rangeLeft) Meets(backward rangeLeft) bool {
+	return s.left+backward.left >= RANGE_BETWEEN_STOPS
+}
+
+// checkPath fails unless a path runs from one node to another along
+// vertices of the graph costing the given total, without repeating a node.
+func checkPath(t *testing.T, path []*Node[name], from, to *Node[name], cost float64) {
+	if len(path) == 0 || path[0] != from || path[len(path)-1] != to {
+		t.Errorf("path %v does not run from %s to %s", path, from.Record, to.Record)
+		return
+	}
+	total := 0.0
+	onPath := map[*Node[name]]bool{from: true}
+	for i := 1; i < len(path); i++ {
+		v := path[i-1].VertexTo(path[i])
+		if v == nil {
+			t.Errorf("path %v has no vertex from %s to %s", path, path[i-1].Record, path[i].Record)
+			return
+		}
+		if onPath[path[i]] {
+			t.Errorf("path %v visits %s twice", path, path[i].Record)
+		}
+		onPath[path[i]] = true
+		total += v.Cost
+	}
+	if math.Abs(total-cost) > 1e-9 {
+		t.Errorf("path %v costs %f rather than %f", path, total, cost)
+	}
+}
+
+func testTraverseBidirectional[S TraverseState[name, S]](t *testing.T, forward, backward S, frozen bool) {
+	r := rand.New(rand.NewSource(8))
+	g, nodes := randomGraph[S](r, 150)
+	// some one-way vertices, so that searching backward must follow them
+	// against their direction
+	for i := 0; i < 100; i++ {
+		g.ConnectUni(nodes[r.Intn(len(nodes))], nodes[r.Intn(len(nodes))], float64(1+r.Intn(20)))
+	}
+	if frozen {
+		g.Freeze()
+	}
+
+	for trial := 0; trial < 200; trial++ {
+		from, to := nodes[r.Intn(len(nodes))], nodes[r.Intn(len(nodes))]
+		_, expected, expectedOk := g.Traverse(forward, from, to)
+		path, cost, ok := g.TraverseBidirectional(forward, backward, from, to)
+		if ok != expectedOk || math.Abs(cost-expected) > 1e-9 {
+			t.Errorf("%s to %s costs %f (%t) from both ends but %f (%t) from one", from.Record, to.Record, cost, ok, expected, expectedOk)
+		} else if ok {
+			checkPath(t, path, from, to, cost)
+		}
+	}
+}
+
+func TestTraverseBidirectional(t *testing.T) {
+	for _, frozen := range []bool{false, true} {
+		testTraverseBidirectional(t, anyPath{}, anyPath{}, frozen)
+		testTraverseBidirectional(t, rangeLeft{RANGE_BETWEEN_STOPS, false}, rangeLeft{RANGE_BETWEEN_STOPS, true}, frozen)
+	}
+}
+
+// TestTraverseMulti checks that the best pair of several sources and
+// several targets, with offsets, is as cheap as the best of the pairs
+// traversed one at a time.
+func TestTraverseMulti(t *testing.T) {
+	r := rand.New(rand.NewSource(13))
+	g, nodes := randomGraph[anyPath](r, 150)
+	endpoints := func() []Endpoint[name] {
+		e := make([]Endpoint[name], 1+r.Intn(4))
+		for i := range e {
+			e[i] = Endpoint[name]{nodes[r.Intn(len(nodes))], float64(r.Intn(30))}
+		}
+		return e
+	}
+	for trial := 0; trial < 40; trial++ {
+		sources, targets := endpoints(), endpoints()
+		expected := math.Inf(1)
+		for _, s := range sources {
+			for _, to := range targets {
+				if _, cost, ok := g.Traverse(anyPath{}, s.Node, to.Node); ok {
+					expected = math.Min(expected, s.Offset+cost+to.Offset)
+				}
+			}
+		}
+
+		path, cost, ok, err := g.TraverseMulti(context.Background(), anyPath{}, sources, targets, 0)
+		if err != nil || !ok || cost != expected {
+			t.Errorf("trial %d: best pair costs %f (%t, %v) but %f one at a time", trial, cost, ok, err, expected)
+			continue
+		}
+		// some pair of the path's ends must account for the cost
+		paired := false
+		for _, s := range sources {
+			for _, to := range targets {
+				if s.Node == path[0] && to.Node == path[len(path)-1] && math.Abs(s.Offset+to.Offset+pathCost(path)-cost) < 1e-9 {
+					paired = true
+				}
+			}
+		}
+		if !paired {
+			t.Errorf("trial %d: path %v costing %f joins no source and target", trial, path, cost)
+		}
+		checkPath(t, path, path[0], path[len(path)-1], pathCost(path))
+	}
+}
+
+// pathCost adds up the cheapest vertices between consecutive nodes.
+func pathCost(path []*Node[name]) (total float64) {
+	for i := 1; i < len(path); i++ {
+		total += path[i-1].VertexTo(path[i]).Cost
+	}
+	return
+}
+
+func BenchmarkTraverse(b *testing.B) {
+	for _, frozen := range []bool{false, true} {
+		r := rand.New(rand.NewSource(7))
+		g, nodes := randomGraph[anyPath](r, 2000)
+		if frozen {
+			g.Freeze()
+		}
+		b.Run(fmt.Sprintf("frozen=%t", frozen), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				g.Traverse(anyPath{}, nodes[i%len(nodes)], nodes[(i*7+1000)%len(nodes)])
+			}
+		})
+	}
+}
+
+// untypedState stops after a set number of vertices.
+type untypedState int
+
+func (s untypedState) TraverseStateHelper(v *UntypedVertex) (PrivateTraverseState, bool) {
+	return s - 1, s > 0
+}
+
+func TestUntypedGraph(t *testing.T) {
+	g := NewUntypedGraph()
+	a, b, c := g.NewNode(name("a")), g.NewNode(name("b")), g.NewNode(name("c"))
+	g.ConnectBi(a, b, 1.0)
+	g.ConnectBi(b, c, 1.0)
+	g.ConnectBi(a, c, 5.0)
+
+	if path, cost, ok := g.Traverse(untypedState(2), a, c); !ok || cost != 2.0 || len(path) != 3 {
+		t.Errorf("with two vertices allowed a to c costs %f via %v", cost, path)
+	}
+	if path, cost, ok := g.Traverse(untypedState(1), a, c); !ok || cost != 5.0 || len(path) != 2 {
+		t.Errorf("with one vertex allowed a to c costs %f via %v", cost, path)
+	}
+}
+
+// isStopNamed returns a test for stops by the first letter of their names.
+func isStopNamed(letter byte) func(*Node[name]) bool {
+	return func(n *Node[name]) bool {
+		return n.Record[0] == letter
+	}
+}
+
+func TestHops(t *testing.T) {
+	g := NewGraph[name, anyPath]()
+	s0, s1, s2, s3, a := g.NewNode("s0"), g.NewNode("s1"), g.NewNode("s2"), g.NewNode("s3"), g.NewNode("a")
+	g.ConnectBi(s0, a, 1.0)
+	g.ConnectBi(a, s1, 2.0)
+	g.ConnectBi(s0, s2, 5.0)
+	g.ConnectBi(a, s2, 1.0)
+	g.ConnectBi(s1, s3, 1.0)
+
+	describe := func(hops []Hop[name]) map[name]string {
+		result := make(map[name]string)
+		for _, h := range hops {
+			result[h.To.Record] = fmt.Sprintf("%v %.0f", h.Nodes(), h.Cost)
+		}
+		return result
+	}
+	// s3 lies beyond s1, so is no hop from s0
+	expected := map[name]string{"s1": "[s0 a s1] 3", "s2": "[s0 a s2] 2"}
+	if got := describe(g.Hops(s0, isStopNamed('s'), nil)); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("hops from s0 are %v rather than %v", got, expected)
+	}
+
+	noShortcut := func(v *Vertex[name]) bool { return v.From != a || v.To != s2 }
+	expected = map[name]string{"s1": "[s0 a s1] 3", "s2": "[s0 s2] 5"}
+	if got := describe(g.Hops(s0, isStopNamed('s'), noShortcut)); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("hops from s0 avoiding a to s2 are %v rather than %v", got, expected)
+	}
+	expected = map[name]string{"s2": "[s0 a s2] 2"}
+	if got := describe(g.hopsWithin(s0, isStopNamed('s'), nil, nil, 2.0)); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("hops from s0 costing at most 2 are %v rather than %v", got, expected)
+	}
+}
+
+// TestMinimaxPath reaches x either through a, with no hop costing more
+// than 5 but 10 in all, or directly at 7. The hop on to t costs 8, more
+// than either, so going directly is cheaper in total for the same
+// bottleneck.
+func TestMinimaxPath(t *testing.T) {
+	g := NewGraph[name, anyPath]()
+	s, a, x, t2 := g.NewNode("s"), g.NewNode("sa"), g.NewNode("sx"), g.NewNode("st")
+	g.ConnectBi(s, a, 5.0)
+	g.ConnectBi(a, x, 5.0)
+	g.ConnectBi(s, x, 7.0)
+	g.ConnectBi(x, t2, 8.0)
+
+	stops, bottleneck, ok := g.MinimaxPath(s, t2, isStopNamed('s'), nil)
+	if !ok || bottleneck != 8.0 || fmt.Sprint(stops) != "[s sx st]" {
+		t.Errorf("s to st has bottleneck %f (%t) via %v rather than 8 via [s sx st]", bottleneck, ok, stops)
+	}
+	stops, bottleneck, ok = g.MinimaxPath(s, x, isStopNamed('s'), nil)
+	if !ok || bottleneck != 5.0 || fmt.Sprint(stops) != "[s sa sx]" {
+		t.Errorf("s to sx has bottleneck %f (%t) via %v rather than 5 via [s sa sx]", bottleneck, ok, stops)
+	}
+	if _, _, ok = g.MinimaxPath(s, t2, isStopNamed('s'), func(v *Vertex[name]) bool { return v.To != t2 }); ok {
+		t.Errorf("s reaches st without any vertex to it")
+	}
+	if _, _, ok = g.MinimaxPathWithin(s, t2, isStopNamed('s'), nil, nil, 7.5); ok {
+		t.Errorf("s reaches st without the hop costing 8")
+	}
+	stops, bottleneck, ok = g.MinimaxPathWithin(s, x, isStopNamed('s'), nil, nil, 6.0)
+	if !ok || bottleneck != 5.0 || fmt.Sprint(stops) != "[s sa sx]" {
+		t.Errorf("s to sx within 6 has bottleneck %f (%t) via %v rather than 5 via [s sa sx]", bottleneck, ok, stops)
+	}
+}
+
+// capped allows only vertices costing no more than itself.
+type capped float64
+
+func (c capped) TraverseStateHelper(v *Vertex[name]) (capped, bool) {
+	return c, v.Cost <= float64(c)
+}
+
+// TestMinimaxPathRandom checks on random graphs, with every node a stop,
+// that no path has a cheaper costliest vertex than the one found, and that
+// none with that costliest vertex is cheaper in total.
+func TestMinimaxPathRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(17))
+	g, nodes := randomGraph[capped](r, 60)
+	everyNode := func(*Node[name]) bool { return true }
+	for trial := 0; trial < 100; trial++ {
+		from, to := nodes[r.Intn(len(nodes))], nodes[r.Intn(len(nodes))]
+		if from == to {
+			continue
+		}
+		stops, bottleneck, ok := g.MinimaxPath(from, to, everyNode, nil)
+		if !ok {
+			t.Errorf("%s to %s not found", from.Record, to.Record)
+			continue
+		}
+		if _, _, cheaper := g.Traverse(capped(bottleneck-0.5), from, to); cheaper {
+			t.Errorf("%s to %s can be made with every vertex cheaper than %f", from.Record, to.Record, bottleneck)
+		}
+		_, expected, _ := g.Traverse(capped(bottleneck), from, to)
+		checkPath(t, stops, from, to, expected)
+	}
+}
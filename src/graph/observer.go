@@ -0,0 +1,215 @@
+package graph
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Rejection says why a traversal did not push the state a vertex led to.
+type Rejection int
+
+const (
+	REJECT_VISITED   Rejection = iota // the vertex leads back onto the path
+	REJECT_STATE                      // the private state forbids the vertex
+	REJECT_DOMINATED                  // a state already pushed is as good
+	REJECTION_KINDS
+)
+
+func (r Rejection) String() string {
+	switch r {
+	case REJECT_VISITED:
+		return "visited"
+	case REJECT_STATE:
+		return "forbidden"
+	case REJECT_DOMINATED:
+		return "dominated"
+	}
+	return "unknown"
+}
+
+// A TraverseObserver is told of each step of a traversal, for diagnostics
+// and statistics. A traversal calls it only from its own goroutine, so one
+// observer should not be shared by traversals running concurrently.
+//
+// Vertices are passed the way the graph holds them, so in the backward half
+// of a bidirectional traversal a vertex's From is the node being moved to.
+type TraverseObserver[R NodeRecord] interface {
+	Started(from, to []*Node[R])
+	// Pushed is told of each state queued and how many are then queued.
+	Pushed(v *Vertex[R], totalCost float64, queued int)
+	// Popped is told of each state taken from the queue, and whether it was
+	// expanded or discarded as no better than one already expanded.
+	Popped(n *Node[R], totalCost float64, expanded bool)
+	Rejected(v *Vertex[R], reason Rejection)
+	Reached(n *Node[R], totalCost float64)
+	Finished(ok bool)
+}
+
+// ObservedTraverseState is implemented by private states that want their
+// traversals observed. Observer may return nil for none.
+type ObservedTraverseState[R NodeRecord] interface {
+	Observer() TraverseObserver[R]
+}
+
+type nopObserver[R NodeRecord] struct{}
+
+func (nopObserver[R]) Started(from, to []*Node[R])                         {}
+func (nopObserver[R]) Pushed(v *Vertex[R], totalCost float64, queued int)  {}
+func (nopObserver[R]) Popped(n *Node[R], totalCost float64, expanded bool) {}
+func (nopObserver[R]) Rejected(v *Vertex[R], reason Rejection)             {}
+func (nopObserver[R]) Reached(n *Node[R], totalCost float64)               {}
+func (nopObserver[R]) Finished(ok bool)                                    {}
+
+// endpointNodes returns the nodes of a traversal's sources or targets.
+func endpointNodes[R NodeRecord](endpoints []Endpoint[R]) []*Node[R] {
+	nodes := make([]*Node[R], len(endpoints))
+	for i, e := range endpoints {
+		nodes[i] = e.Node
+	}
+	return nodes
+}
+
+// records returns the records of nodes, for logging.
+func records[R NodeRecord](nodes []*Node[R]) []R {
+	rs := make([]R, len(nodes))
+	for i, n := range nodes {
+		rs[i] = n.Record
+	}
+	return rs
+}
+
+// observerFor returns the observer a private state asks for, or one that
+// ignores everything.
+func observerFor[R NodeRecord](privateState any) TraverseObserver[R] {
+	if observed, ok := privateState.(ObservedTraverseState[R]); ok {
+		if observer := observed.Observer(); observer != nil {
+			return observer
+		}
+	}
+	return nopObserver[R]{}
+}
+
+// Observers tells each of its observers of every step in turn.
+type Observers[R NodeRecord] []TraverseObserver[R]
+
+func (obs Observers[R]) Started(from, to []*Node[R]) {
+	for _, o := range obs {
+		o.Started(from, to)
+	}
+}
+
+func (obs Observers[R]) Pushed(v *Vertex[R], totalCost float64, queued int) {
+	for _, o := range obs {
+		o.Pushed(v, totalCost, queued)
+	}
+}
+
+func (obs Observers[R]) Popped(n *Node[R], totalCost float64, expanded bool) {
+	for _, o := range obs {
+		o.Popped(n, totalCost, expanded)
+	}
+}
+
+func (obs Observers[R]) Rejected(v *Vertex[R], reason Rejection) {
+	for _, o := range obs {
+		o.Rejected(v, reason)
+	}
+}
+
+func (obs Observers[R]) Reached(n *Node[R], totalCost float64) {
+	for _, o := range obs {
+		o.Reached(n, totalCost)
+	}
+}
+
+func (obs Observers[R]) Finished(ok bool) {
+	for _, o := range obs {
+		o.Finished(ok)
+	}
+}
+
+// TraverseStats counts the steps of the traversals it observes, one after
+// another, and the time they take.
+type TraverseStats[R NodeRecord] struct {
+	Traversals int
+	Pushes     int
+	Pops       int
+	Expansions int
+	Rejections [REJECTION_KINDS]int // by reason
+	MaxQueued  int
+	Elapsed    time.Duration
+	started    time.Time
+}
+
+func (s *TraverseStats[R]) Started(from, to []*Node[R]) {
+	s.Traversals++
+	s.started = time.Now()
+}
+
+func (s *TraverseStats[R]) Pushed(v *Vertex[R], totalCost float64, queued int) {
+	s.Pushes++
+	if queued > s.MaxQueued {
+		s.MaxQueued = queued
+	}
+}
+
+func (s *TraverseStats[R]) Popped(n *Node[R], totalCost float64, expanded bool) {
+	s.Pops++
+	if expanded {
+		s.Expansions++
+	}
+}
+
+func (s *TraverseStats[R]) Rejected(v *Vertex[R], reason Rejection) {
+	s.Rejections[reason]++
+}
+
+func (s *TraverseStats[R]) Reached(n *Node[R], totalCost float64) {}
+
+func (s *TraverseStats[R]) Finished(ok bool) {
+	s.Elapsed += time.Since(s.started)
+}
+
+// LogValue lets the stats be logged as a group of attributes.
+func (s *TraverseStats[R]) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Int("traversals", s.Traversals),
+		slog.Int("pushes", s.Pushes),
+		slog.Int("pops", s.Pops),
+		slog.Int("expansions", s.Expansions),
+	}
+	for r := Rejection(0); r < REJECTION_KINDS; r++ {
+		attrs = append(attrs, slog.Int(r.String(), s.Rejections[r]))
+	}
+	attrs = append(attrs, slog.Int("max_queued", s.MaxQueued), slog.Duration("elapsed", s.Elapsed))
+	return slog.GroupValue(attrs...)
+}
+
+// LogObserver logs every step of a traversal at debug level.
+type LogObserver[R NodeRecord] struct {
+	Logger *slog.Logger
+}
+
+func (l LogObserver[R]) Started(from, to []*Node[R]) {
+	l.Logger.Debug("traverse", "from", records(from), "to", records(to))
+}
+
+func (l LogObserver[R]) Pushed(v *Vertex[R], totalCost float64, queued int) {
+	l.Logger.Debug("push", "from", v.From.Record, "to", v.To.Record, "cost", totalCost, "queued", queued)
+}
+
+func (l LogObserver[R]) Popped(n *Node[R], totalCost float64, expanded bool) {
+	l.Logger.Debug("pop", "node", n.Record, "cost", totalCost, "expanded", expanded)
+}
+
+func (l LogObserver[R]) Rejected(v *Vertex[R], reason Rejection) {
+	l.Logger.Debug("reject", "from", v.From.Record, "to", v.To.Record, "reason", reason)
+}
+
+func (l LogObserver[R]) Reached(n *Node[R], totalCost float64) {
+	l.Logger.Debug("reach", "node", n.Record, "cost", totalCost)
+}
+
+func (l LogObserver[R]) Finished(ok bool) {
+	l.Logger.Debug("traversed", "ok", ok)
+}
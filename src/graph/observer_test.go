@@ -0,0 +1,61 @@
+package graph
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// observedPath is anyPath with an observer.
+type observedPath struct {
+	observer TraverseObserver[name]
+}
+
+func (s observedPath) TraverseStateHelper(v *Vertex[name]) (observedPath, bool) {
+	return s, true
+}
+
+func (s observedPath) Observer() TraverseObserver[name] {
+	return s.observer
+}
+
+// reaching remembers the last node a traversal reached.
+type reaching struct {
+	nopObserver[name]
+	node *Node[name]
+	cost float64
+}
+
+func (r *reaching) Reached(n *Node[name], totalCost float64) {
+	r.node, r.cost = n, totalCost
+}
+
+func TestTraverseStats(t *testing.T) {
+	g, nodes := randomGraph[observedPath](rand.New(rand.NewSource(8)), 60)
+	r := rand.New(rand.NewSource(9))
+	for trial := 0; trial < 10; trial++ {
+		from, to := nodes[r.Intn(len(nodes))], nodes[r.Intn(len(nodes))]
+		_, expected, _ := g.Traverse(observedPath{}, from, to)
+
+		stats, reached := &TraverseStats[name]{}, &reaching{}
+		state := observedPath{Observers[name]{stats, reached}}
+		_, cost, ok := g.Traverse(state, from, to)
+		if !ok || cost != expected {
+			t.Fatalf("%s to %s costs %f observed but %f not", from.Record, to.Record, cost, expected)
+		}
+		if reached.node != to || reached.cost != cost {
+			t.Errorf("%s to %s reached %v at %f", from.Record, to.Record, reached.node, reached.cost)
+		}
+		// the first state is never pushed
+		if stats.Traversals != 1 || stats.Expansions > stats.Pops || stats.Pops > stats.Pushes+1 {
+			t.Errorf("%s to %s has inconsistent stats %+v", from.Record, to.Record, *stats)
+		}
+		if from != to && (stats.Expansions < 2 || stats.MaxQueued == 0) {
+			t.Errorf("%s to %s has too few steps counted %+v", from.Record, to.Record, *stats)
+		}
+
+		_, cost, _ = g.TraverseBidirectional(state, observedPath{}, from, to)
+		if cost != expected || stats.Traversals != 2 || reached.cost != expected {
+			t.Errorf("%s to %s costs %f from both ends, observed %+v", from.Record, to.Record, cost, *stats)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package graph
+
+import (
+	sheap "slice_heap"
+	"sort"
+)
+
+// An Overlay is a HopGraph that remembers which stop of the graph it was
+// made from each of its nodes stands for, and the way through that graph
+// each of its vertices takes, so that paths found over it can be laid back
+// onto the graph. It describes the graph as it was when made or last
+// updated.
+type Overlay[R NodeRecord, S TraverseState[R, S]] struct {
+	*Graph[R, S]
+	stops   map[*Node[R]]*Node[R] // overlay nodes by the stops they stand for
+	base    []*Node[R]            // the stops by overlay node id
+	hops    map[Link[R]]Hop[R]    // by the overlay nodes each vertex runs from and to
+	of      *Graph[R, S]          // the graph it was made from
+	isStop  func(*Node[R]) bool
+	allow   func(*Vertex[R]) bool
+	cost    func(*Vertex[R]) float64 // nil for each vertex's Cost
+	maxCost float64
+}
+
+// Overlay returns a frozen graph of g's stops with a vertex for each hop
+// between them costing no more than maxCost, using only the vertices
+// allowed (all, if allow is nil) and costing each by vertexCost (its Cost,
+// if vertexCost is nil).
+func (g *Graph[R, S]) Overlay(isStop func(*Node[R]) bool, allow func(*Vertex[R]) bool, vertexCost func(*Vertex[R]) float64, maxCost float64) *Overlay[R, S] {
+	o := &Overlay[R, S]{NewGraph[R, S](), make(map[*Node[R]]*Node[R]), make([]*Node[R], 0), make(map[Link[R]]Hop[R]), g, isStop, allow, vertexCost, maxCost}
+	for _, n := range g.nodes {
+		if isStop(n) {
+			o.stops[n] = o.NewNode(n.Record)
+			o.base = append(o.base, n)
+		}
+	}
+
+	for _, n := range g.nodes {
+		if o.stops[n] == nil {
+			continue
+		}
+		for _, hop := range g.hopsWithin(n, isStop, allow, vertexCost, maxCost) {
+			from, to := o.stops[hop.From], o.stops[hop.To]
+			o.ConnectUni(from, to, hop.Cost)
+			o.hops[Link[R]{from, to}] = hop
+		}
+	}
+
+	o.Freeze()
+	return o
+}
+
+// Update brings the overlay up to date after the graph it was made from has
+// changed, given the nodes near which its hops could have: every node added
+// or whose vertices changed (see Thawed), and every node with a vertex the
+// overlay's allow function now judges differently. Only the hops of stops
+// that could reach one of them are worked out again. The graph must be
+// frozen.
+func (o *Overlay[R, S]) Update(near []*Node[R]) {
+	gone := make(map[*Node[R]]bool)
+	for _, stop := range o.base {
+		if !o.of.Has(stop) {
+			gone[o.stops[stop]] = true
+			delete(o.stops, stop)
+		}
+	}
+
+	affected := o.of.stopsBefore(near, o.isStop, o.cost, o.maxCost)
+	for _, stop := range affected {
+		if o.stops[stop] == nil {
+			o.stops[stop] = o.NewNode(stop.Record)
+		}
+	}
+	for link := range o.hops {
+		if gone[link.A] || gone[link.B] {
+			delete(o.hops, link)
+		}
+	}
+	for _, stop := range affected {
+		from := o.stops[stop]
+		for i := 0; i < from.degree(); i++ {
+			delete(o.hops, Link[R]{from, from.vertex(i).To})
+		}
+		o.disconnect(from)
+		for _, hop := range o.of.hopsWithin(stop, o.isStop, o.allow, o.cost, o.maxCost) {
+			to := o.stops[hop.To]
+			o.ConnectUni(from, to, hop.Cost)
+			o.hops[Link[R]{from, to}] = hop
+		}
+	}
+
+	doomed := make([]*Node[R], 0, len(gone))
+	for n := range gone {
+		doomed = append(doomed, n)
+	}
+	o.RemoveNodes(doomed...)
+	o.base = make([]*Node[R], len(o.nodes))
+	for stop, n := range o.stops {
+		o.base[n.id] = stop
+	}
+	o.Freeze()
+}
+
+// stopsBefore returns, in id order, the stops from which a hop costing no
+// more than maxCost, by vertexCost as for hopsWithin, could reach any of the
+// given nodes, whatever vertices it is allowed, including those of the nodes
+// that are stops. It works back along the vertices arriving at each node, so
+// the graph must be frozen.
+func (g *Graph[R, S]) stopsBefore(near []*Node[R], isStop func(*Node[R]) bool, vertexCost func(*Vertex[R]) float64, maxCost float64) []*Node[R] {
+	seen := make([]bool, len(g.nodes))
+	isNear := make([]bool, len(g.nodes))
+	sh := sheap.NewSliceHeap(hopStateLessThan[R])
+	sequence := 0
+	for _, n := range near {
+		if g.Has(n) && !isNear[n.id] {
+			isNear[n.id] = true
+			sh.PushItem(&hopState[R]{n, 0.0, sequence, nil})
+			sequence++
+		}
+	}
+
+	stops := make([]*Node[R], 0)
+	for !sh.IsEmpty() {
+		state := sh.PopItem()
+		if seen[state.node.id] {
+			continue
+		}
+		seen[state.node.id] = true
+
+		if isStop(state.node) {
+			stops = append(stops, state.node)
+			// a hop passes through no stop, but may end at one near
+			if !isNear[state.node.id] {
+				continue
+			}
+		}
+
+		for _, vertex := range g.incoming[state.node.id] {
+			if cost := state.cost + costBy(vertexCost, vertex); !seen[vertex.From.id] && cost <= maxCost {
+				sequence++
+				sh.PushItem(&hopState[R]{vertex.From, cost, sequence, nil})
+			}
+		}
+	}
+
+	sort.Slice(stops, func(i, j int) bool { return stops[i].id < stops[j].id })
+	return stops
+}
+
+// NodeFor returns the overlay node standing for a stop of the graph, or nil
+// if the node is not a stop.
+func (o *Overlay[R, S]) NodeFor(stop *Node[R]) *Node[R] {
+	return o.stops[stop]
+}
+
+// Expand lays a path over the overlay back onto the graph it was made from.
+func (o *Overlay[R, S]) Expand(path []*Node[R]) (expanded []*Node[R]) {
+	expanded = make([]*Node[R], 0)
+	if len(path) == 1 {
+		expanded = append(expanded, o.base[path[0].id])
+	}
+	for i := 1; i < len(path); i++ {
+		nodes := o.hops[Link[R]{path[i-1], path[i]}].Nodes()
+		if i > 1 {
+			nodes = nodes[1:]
+		}
+		expanded = append(expanded, nodes...)
+	}
+	return
+}
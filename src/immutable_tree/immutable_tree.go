@@ -9,11 +9,16 @@ type Comparable interface {
 	String() string
 }
 
+// node is never changed once made, so trees built from one another share
+// whatever nodes they have in common.
 type node struct {
 	value       Comparable
 	left, right *node
+	height      int
 }
 
+// Tree is a persistent set kept balanced as an AVL tree, so adding a value
+// or looking one up takes time logarithmic in the size of the set.
 type Tree struct {
 	root *node
 }
@@ -22,48 +27,81 @@ func NewTree() *Tree {
 	return &Tree{nil}
 }
 
-func (thisNode *node) addHelper(newNode *node) *node {
-	// compare := (*newNode.value).CompareTo(thisNode.value)
-	compare := newNode.value.CompareTo(thisNode.value)
+func (n *node) getHeight() int {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+// newNode makes a node from its parts, working out its height.
+func newNode(value Comparable, left, right *node) *node {
+	height := left.getHeight()
+	if right.getHeight() > height {
+		height = right.getHeight()
+	}
+	return &node{value, left, right, height + 1}
+}
+
+// balance makes a node from its parts, rotating if the subtrees' heights
+// differ by more than one.
+func balance(value Comparable, left, right *node) *node {
+	switch {
+	case left.getHeight() > right.getHeight()+1:
+		if left.right.getHeight() > left.left.getHeight() {
+			// left-right case
+			return newNode(left.right.value,
+				newNode(left.value, left.left, left.right.left),
+				newNode(value, left.right.right, right))
+		}
+		return newNode(left.value, left.left, newNode(value, left.right, right))
+	case right.getHeight() > left.getHeight()+1:
+		if right.left.getHeight() > right.right.getHeight() {
+			// right-left case
+			return newNode(right.left.value,
+				newNode(value, left, right.left.left),
+				newNode(right.value, right.left.right, right.right))
+		}
+		return newNode(right.value, newNode(value, left, right.left), right.right)
+	}
+	return newNode(value, left, right)
+}
+
+func (thisNode *node) addHelper(value Comparable) *node {
+	if thisNode == nil {
+		return newNode(value, nil, nil)
+	}
+
+	compare := value.CompareTo(thisNode.value)
 	if compare < 0 {
-		if thisNode.left == nil {
-			return &node{thisNode.value, newNode, thisNode.right}
-		} else {
-			newLeft := thisNode.left.addHelper(newNode)
-			if newLeft == thisNode.left {
-				return thisNode
-			} else {
-				return &node{thisNode.value, newLeft, thisNode.right}
-			}
+		newLeft := thisNode.left.addHelper(value)
+		if newLeft == thisNode.left {
+			return thisNode
 		}
+		return balance(thisNode.value, newLeft, thisNode.right)
 	} else if compare > 0 {
-		if thisNode.right == nil {
-			return &node{thisNode.value, thisNode.left, newNode}
-		} else {
-			newRight := thisNode.right.addHelper(newNode)
-			if newRight == thisNode.right {
-				return thisNode
-			} else {
-				return &node{thisNode.value, thisNode.left, newRight}
-			}
+		newRight := thisNode.right.addHelper(value)
+		if newRight == thisNode.right {
+			return thisNode
 		}
+		return balance(thisNode.value, thisNode.left, newRight)
 	}
 	return thisNode
 }
 
+// AddValue returns a tree holding the value as well as everything in t,
+// which is left unchanged. If t already holds the value, t is returned.
 func (t *Tree) AddValue(value Comparable) (result *Tree) {
-	newNode := &node{value, nil, nil}
-	if t.root == nil {
-		return &Tree{newNode}
-	} else {
-		newRoot := t.root.addHelper(newNode)
-		if newRoot == t.root {
-			return t
-		} else {
-			return &Tree{newRoot}
-		}
+	newRoot := t.root.addHelper(value)
+	if newRoot == t.root {
+		return t
 	}
-	return nil // should never be executed
+	return &Tree{newRoot}
+}
+
+// Height returns the number of levels in the tree.
+func (t *Tree) Height() int {
+	return t.root.getHeight()
 }
 
 func (t *Tree) HasValue(value Comparable) bool {
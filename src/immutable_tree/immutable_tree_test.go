@@ -61,3 +61,38 @@ func TestStrings(t *testing.T) {
 		}
 	}
 }
+
+func TestBalance(t *testing.T) {
+	// in-order insertion degenerates into a list without rebalancing
+	tree := NewTree()
+	count := 1000
+	for v := 0; v < count; v++ {
+		iv := IntValue(v)
+		tree = tree.AddValue(&iv)
+	}
+
+	// an AVL tree is never more than about 1.44 log2(n) high
+	if tree.Height() > 15 {
+		t.Errorf("tree of %d values is %d high", count, tree.Height())
+	}
+	for v := 0; v < count; v++ {
+		iv := IntValue(v)
+		if !tree.HasValue(&iv) {
+			t.Errorf("missing value %d", v)
+		}
+	}
+	iv := IntValue(count)
+	if tree.HasValue(&iv) {
+		t.Error("extra value")
+	}
+}
+
+func BenchmarkAddValue(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		tree := NewTree()
+		for v := 0; v < 100; v++ {
+			iv := IntValue(v)
+			tree = tree.AddValue(&iv)
+		}
+	}
+}
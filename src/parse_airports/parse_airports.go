@@ -9,20 +9,36 @@ import (
 )
 
 const (
+	SITE_INDEX   = 0
 	ABBREV_INDEX = 2
 	NAME_INDEX   = 11
 	LAT_INDEX    = 22
 	LON_INDEX    = 24
 )
 
+// Column names looked up in the header of the NFDC runways file.
+const (
+	RUNWAY_SITE_COLUMN    = "SiteNumber"
+	RUNWAY_LENGTH_COLUMN  = "RunwayLength"
+	RUNWAY_HEADING_COLUMN = "BaseEndTrueAlignment"
+)
+
 type airport struct {
 	latitude, longitude float64
 	name, nickname      string
+	siteNumber          string
+}
+
+type runway struct {
+	lengthFt   int
+	headingDeg int // true, of the base end; -1 if unknown
 }
 
 // The flag package provides a default help printer via -h switch
 var inputFile *string = flag.String("i", "NfdcFacilities.csv", "Input CSV file name.")
 var outputFile *string = flag.String("o", "usairports.in", "Output file name.")
+var runwayFile *string = flag.String("rw", "", "Input NFDC runways CSV file name; enables the attributes file.")
+var attributesFile *string = flag.String("a", "usairports.attrs", "Output airport attributes file name.")
 
 func parseLatLon(in string) float64 {
 	var degrees, minutes int
@@ -73,7 +89,7 @@ func main() {
 		longitude := parseLatLon(fields[LON_INDEX])
 		fmt.Printf("%s : %s : (%f, %f)\n", fields[ABBREV_INDEX], fields[NAME_INDEX], latitude, longitude)
 
-		ap := airport{latitude, longitude, fields[NAME_INDEX], fields[ABBREV_INDEX]}
+		ap := airport{latitude, longitude, fields[NAME_INDEX], fields[ABBREV_INDEX], fields[SITE_INDEX]}
 		airports = append(airports, ap)
 	}
 
@@ -90,4 +106,75 @@ func main() {
 	
 	fmt.Fprintf(out, "1\n")
 	fmt.Fprintf(out, "%q %q 1000\n", "LAX", "LGA")
+
+	if *runwayFile != "" {
+		writeAttributes(airports, readRunways(*runwayFile))
+	}
+}
+
+// readRunways returns the runways of each airport keyed by site number.
+func readRunways(fileName string) map[string][]runway {
+	in, err := os.Open(fileName)
+	if err != nil {
+		panic("couldn't open runway file \"" + fileName + "\"")
+	}
+	defer func() { in.Close() }()
+
+	csvReader := csv.NewReader(in)
+	csvReader.TrailingComma = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		panic(fmt.Sprintf("Error reading runway header -- %s.", err))
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{RUNWAY_SITE_COLUMN, RUNWAY_LENGTH_COLUMN, RUNWAY_HEADING_COLUMN} {
+		if _, found := columns[name]; !found {
+			panic("runway file has no \"" + name + "\" column")
+		}
+	}
+
+	runways := make(map[string][]runway)
+	for {
+		fields, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			break
+		}
+
+		var rw runway
+		if _, err = fmt.Sscanf(fields[columns[RUNWAY_LENGTH_COLUMN]], "%d", &rw.lengthFt); err != nil {
+			continue // helipads and the like have no length
+		}
+		if _, err = fmt.Sscanf(fields[columns[RUNWAY_HEADING_COLUMN]], "%d", &rw.headingDeg); err != nil {
+			rw.headingDeg = -1
+		}
+		site := fields[columns[RUNWAY_SITE_COLUMN]]
+		runways[site] = append(runways[site], rw)
+	}
+
+	return runways
+}
+
+// writeAttributes writes a line per airport of its abbreviation followed by
+// the length in feet and true heading in degrees of each of its runways.
+func writeAttributes(airports []airport, runways map[string][]runway) {
+	out, err := os.Create(*attributesFile)
+	if err != nil {
+		panic("couldn't open attributes file \"" + *attributesFile + "\"")
+	}
+	defer func() { out.Close() }()
+
+	for _, ap := range airports {
+		fmt.Fprintf(out, "%q", ap.nickname)
+		for _, rw := range runways[ap.siteNumber] {
+			fmt.Fprintf(out, " %d %d", rw.lengthFt, rw.headingDeg)
+		}
+		fmt.Fprintln(out)
+	}
 }
@@ -1,28 +1,25 @@
 package priority_queue
 
 import (
-	"container/heap"
 	sheap "slice_heap"
 )
 
-type PriorityQueue struct {
-	myHeap *sheap.SliceHeap
+type PriorityQueue[T any] struct {
+	myHeap *sheap.SliceHeap[T]
 }
 
-func NewPriorityQueue(f func(a, b interface{}) bool) *PriorityQueue {
-	sh := sheap.NewSliceHeap(f)
-	heap.Init(sh)
-	return &PriorityQueue{sh}
+func NewPriorityQueue[T any](f func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{sheap.NewSliceHeap(sheap.LessThanFunc[T](f))}
 }
 
-func (pq *PriorityQueue) Push(item interface{}) {
-	heap.Push(pq.myHeap, item)
+func (pq *PriorityQueue[T]) Push(item T) {
+	pq.myHeap.PushItem(item)
 }
 
-func (pq *PriorityQueue) Pop() interface{} {
-	return heap.Pop(pq.myHeap)
+func (pq *PriorityQueue[T]) Pop() T {
+	return pq.myHeap.PopItem()
 }
 
-func (pq *PriorityQueue) Empty() bool {
-	return 0 == pq.myHeap.Len()
+func (pq *PriorityQueue[T]) Empty() bool {
+	return pq.myHeap.IsEmpty()
 }
@@ -1,7 +1,6 @@
 package priority_queue
 
 import (
-	"container/heap"
 	"testing"
 	"fmt"
 )
@@ -43,4 +42,20 @@ func TestStringPriorityQueue(t *testing.T) {
 			t.Error(fmt.Sprintf("popped %s instead of %s", s, val))
 		}
 	}
-}
\ No newline at end of file
+}
+func TestTypedPriorityQueue(t *testing.T) {
+	pq := NewPriorityQueue(func(a, b string) bool { return len(a) < len(b) })
+	pq.Push("mouse")
+	pq.Push("ox")
+	pq.Push("cat")
+
+	vals := []string{"ox", "cat", "mouse"}
+	for _, val := range vals {
+		if s := pq.Pop(); s != val {
+			t.Errorf("popped %s instead of %s", s, val)
+		}
+	}
+	if !pq.Empty() {
+		t.Error("queue not empty")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var attributesFileName *string = flag.String("attrs", "", "name of airport attributes file written by parse_airports")
+
+// haveRunwayData is set once an attributes file has been read, after which
+// airports it does not describe are taken to have no usable runway.
+var haveRunwayData bool
+
+type runway struct {
+	lengthFt   float64
+	headingDeg float64 // true, of either end; negative if unknown
+}
+
+// readAttributes reads lines of the form
+//
+//	"NAME" lengthFt headingDeg lengthFt headingDeg ...
+//
+// giving the length and true heading of each of an airport's runways.
+func readAttributes(fileName string) map[string][]runway {
+	in, err := os.Open(fileName)
+	if err != nil {
+		panic("couldn't open attributes file \"" + fileName + "\"")
+	}
+	defer func() { in.Close() }()
+
+	result := make(map[string][]runway)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var name string
+		if _, err = fmt.Sscanf(line, "%q", &name); err != nil {
+			panic("couldn't parse attributes line \"" + line + "\"")
+		}
+		fields := strings.Fields(line[strings.LastIndex(line, "\"")+1:])
+		if len(fields)%2 != 0 {
+			panic("runway length without heading in line \"" + line + "\"")
+		}
+
+		runways := make([]runway, 0, len(fields)/2)
+		for i := 0; i < len(fields); i += 2 {
+			var rw runway
+			_, err1 := fmt.Sscanf(fields[i], "%f", &rw.lengthFt)
+			_, err2 := fmt.Sscanf(fields[i+1], "%f", &rw.headingDeg)
+			if err1 != nil || err2 != nil {
+				panic("bad runway in line \"" + line + "\"")
+			}
+			runways = append(runways, rw)
+		}
+		result[name] = runways
+	}
+
+	haveRunwayData = true
+	return result
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const CLOSED = "closed"
+
+var availabilityFileName *string = flag.String("avail", "", "name of airport closures and opening hours file")
+var departureTime *string = flag.String("depart", "0000", "departure time as HHMM UTC, used with opening hours")
+
+// openingHours gives the local times an airport accepts landings; a window
+// that closes before it opens runs past midnight. An aircraft that would
+// land while an airport is shut waits on the ground wherever it last took
+// off, or at the origin, for long enough to land once it opens.
+type openingHours struct {
+	opensMins, closesMins int
+	utcOffsetHours        float64
+}
+
+// availability
+
+type availability struct {
+	closed bool
+	hours  *openingHours // nil when always open
+}
+
+// parseHHMM reads a time of day given as four digits, from 0000 to 2400,
+// in minutes after midnight.
+func parseHHMM(text string) (minutes int, ok bool) {
+	if len(text) != 4 || strings.Trim(text, "0123456789") != "" {
+		return 0, false
+	}
+	hours, _ := strconv.Atoi(text[:2])
+	minutes, _ = strconv.Atoi(text[2:])
+	if minutes >= 60 || hours > 24 || (hours == 24 && minutes > 0) {
+		return 0, false
+	}
+	return hours*60 + minutes, true
+}
+
+// isOpen reports whether the airport accepts a landing the given number of
+// hours after 0000 UTC on the day of departure.
+func (h *openingHours) isOpen(utcHours float64) bool {
+	local := math.Mod((utcHours+h.utcOffsetHours)*60.0, 24*60)
+	if local < 0 {
+		local += 24 * 60
+	}
+	opens, closes := float64(h.opensMins), float64(h.closesMins)
+	if opens <= closes {
+		return local >= opens && local <= closes
+	}
+	return local >= opens || local <= closes
+}
+
+// waitHours returns how long from the given number of hours after 0000 UTC
+// it is until the airport next opens, or 0 if it is open then.
+func (a availability) waitHours(utcHours float64) float64 {
+	if a.hours == nil || a.hours.isOpen(utcHours) {
+		return 0.0
+	}
+	local := math.Mod((utcHours+a.hours.utcOffsetHours)*60.0, 24*60)
+	return math.Mod(float64(a.hours.opensMins)-local+2*24*60, 24*60) / 60.0
+}
+
+// hasOpeningHours reports whether any airport has opening hours.
+func hasOpeningHours(airportAvailability map[string]availability) bool {
+	for _, a := range airportAvailability {
+		if a.hours != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hasClosures reports whether any airport is closed.
+func hasClosures(airportAvailability map[string]availability) bool {
+	for _, a := range airportAvailability {
+		if a.closed {
+			return true
+		}
+	}
+	return false
+}
+
+// readAvailability reads lines of the form
+//
+//	"NAME" closed
+//	"NAME" HHMM HHMM utcOffsetHours
+//
+// where the second form gives local opening and closing times. Blank lines
+// and lines starting with '#' are ignored.
+func readAvailability(fileName string) map[string]availability {
+	in, err := os.Open(fileName)
+	if err != nil {
+		panic("couldn't open availability file \"" + fileName + "\"")
+	}
+	defer func() { in.Close() }()
+
+	result := make(map[string]availability)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var name, opens, closes string
+		var offset float64
+		if _, err = fmt.Sscanf(line, "%q %s", &name, &opens); err != nil {
+			panic("couldn't parse availability line \"" + line + "\"")
+		}
+		if opens == CLOSED {
+			result[name] = availability{true, nil}
+			continue
+		}
+
+		if _, err = fmt.Sscanf(line, "%q %s %s %f", &name, &opens, &closes, &offset); err != nil {
+			panic("couldn't parse availability line \"" + line + "\"")
+		}
+		opensMins, ok1 := parseHHMM(opens)
+		closesMins, ok2 := parseHHMM(closes)
+		if !ok1 || !ok2 {
+			panic("bad opening hours in line \"" + line + "\"")
+		}
+		result[name] = availability{false, &openingHours{opensMins, closesMins, offset}}
+	}
+
+	return result
+}
+
+// departureHours returns the departure time given on the command line in
+// hours after 0000 UTC.
+func departureHours() float64 {
+	minutes, ok := parseHHMM(*departureTime)
+	if !ok {
+		panic("bad departure time \"" + *departureTime + "\"")
+	}
+	return float64(minutes) / 60.0
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+)
+
+// Searching from both ends keeps every arrival at a point with more range
+// left, as the two searches may only meet through one of them, so it may
+// find shorter routes than the usual search, where the first arrival wins.
+var bidirectional *bool = flag.Bool("bidi", false, "search two-airport flights from both ends when range is the only limit")
+
+// newMirroredFlightState starts the search backward from a destination.
+// A mirrored state's remainingRange is how far the aircraft can have flown
+// since its last landing on arriving at the node reached, given how far it
+// still has to fly from there to its next landing.
+func newMirroredFlightState(plan *flightPlan) flightState {
+	return flightState{plan, plan.fullRange, plan.profile.tankKg, 0.0, 0.0, false, true}
+}
+
+// mirroredStateHelper moves a mirrored state against a vertex, from its To
+// to its From.
+func (fs flightState) mirroredStateHelper(v *placeVertex) (newState flightState, ok bool) {
+	airKm := fs.plan.profile.airKm(v)
+	if airKm > fs.remainingRange {
+		return fs, false
+	}
+
+	airport, isAirport := v.From.Record.airport()
+	if isAirport && (airport.closed || !fs.plan.profile.canLandAt(airport)) {
+		return fs, false
+	}
+
+	if fs.plan.diversion != nil && !fs.plan.diversion.allows(v) {
+		return fs, false
+	}
+
+	newFs := fs
+	if isAirport {
+		newFs.remainingRange = fs.plan.fullRange
+	} else {
+		newFs.remainingRange = fs.remainingRange - airKm
+	}
+	return newFs, true
+}
+
+// Meets reports whether the range left on arriving at a node covers what
+// the backward search still has to fly from it.
+func (fs flightState) Meets(backward flightState) bool {
+	return fs.remainingRange+backward.remainingRange >= fs.plan.fullRange
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	g "graph"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var criteriaSpec *string = flag.String("criteria", "", "minimize these comma separated criteria, each breaking the ties of those before, where each is one of "+criteriaNames()+" or a weighted sum such as \"2*time+0.001*fuel\"")
+var paretoSpec *string = flag.String("pareto", "", "print every route of two-airport flights that no other beats by both of two comma separated criteria, as for -criteria")
+
+// Vertex attributes, given every vertex when winds or land are known, and
+// followed by those for windSpeeds.
+const (
+	ATTR_GROUND_KM = iota // distance over the ground, when winds make the cost an air distance
+	ATTR_WATER_KM         // distance over no land area, when land is known
+	ATTR_COUNT
+)
+
+const (
+	WATER_CRITERION     = "water"
+	FUEL_COST_CRITERION = "fuelcost"
+)
+
+type placeCriterion = g.Criterion[place, flightState]
+
+var criteriaByName = map[string]placeCriterion{
+	"distance": func(fs flightState, v *placeVertex) float64 {
+		if attributes := v.Attributes(); attributes != nil {
+			return attributes[ATTR_GROUND_KM]
+		}
+		return v.Cost
+	},
+	"air": func(fs flightState, v *placeVertex) float64 {
+		return fs.plan.profile.airKm(v)
+	},
+	"time": func(fs flightState, v *placeVertex) float64 {
+		return fs.vertexHours(v)
+	},
+	"fuel": func(fs flightState, v *placeVertex) float64 {
+		return fs.plan.profile.fuelKg(fs.plan.profile.vertexAirborneHours(v))
+	},
+	FUEL_COST_CRITERION: func(fs flightState, v *placeVertex) float64 {
+		return fs.fuelCost(v)
+	},
+	"landings": func(fs flightState, v *placeVertex) float64 {
+		if _, landing := v.To.Record.airport(); landing {
+			return 1.0
+		}
+		return 0.0
+	},
+	WATER_CRITERION: g.Attribute[place, flightState](ATTR_WATER_KM),
+}
+
+func criteriaNames() string {
+	names := make([]string, 0, len(criteriaByName))
+	for name := range criteriaByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+type namedCriterion struct {
+	name  string
+	price placeCriterion
+}
+
+// parseCriteria interprets a comma separated list of criteria, each a
+// weighted sum of named ones, insisting on count of them unless count is 0.
+func parseCriteria(spec string, count int) (criteria []namedCriterion) {
+	if spec == "" {
+		return nil
+	}
+	for _, sum := range strings.Split(spec, ",") {
+		weights, terms := make([]float64, 0), make([]placeCriterion, 0)
+		for _, term := range strings.Split(sum, "+") {
+			weight, name := 1.0, strings.TrimSpace(term)
+			if star := strings.Index(name, "*"); star >= 0 {
+				var err error
+				if weight, err = strconv.ParseFloat(strings.TrimSpace(name[:star]), 64); err != nil || weight < 0 {
+					panic("bad weight in criterion \"" + sum + "\"")
+				}
+				name = strings.TrimSpace(name[star+1:])
+			}
+			price, found := criteriaByName[name]
+			if !found {
+				panic("unknown criterion \"" + name + "\"")
+			}
+			if name == WATER_CRITERION && land == nil {
+				panic("the \"" + WATER_CRITERION + "\" criterion needs a land file")
+			}
+			if name == FUEL_COST_CRITERION && *burnRate <= 0 {
+				panic("the \"" + FUEL_COST_CRITERION + "\" criterion needs the fuel model")
+			}
+			weights, terms = append(weights, weight), append(terms, price)
+		}
+		if len(terms) == 1 && weights[0] == 1.0 {
+			criteria = append(criteria, namedCriterion{sum, terms[0]})
+		} else {
+			criteria = append(criteria, namedCriterion{sum, g.Scalarise(weights, terms)})
+		}
+	}
+	if count > 0 && len(criteria) != count {
+		panic(fmt.Sprintf("\"%s\" needs %d criteria", spec, count))
+	}
+	return
+}
+
+func prices(criteria []namedCriterion) []placeCriterion {
+	ps := make([]placeCriterion, len(criteria))
+	for i, c := range criteria {
+		ps[i] = c.price
+	}
+	return ps
+}
+
+// routeCosts adds up a route's cost by each criterion, following the flight
+// state along it as the search did.
+func routeCosts(route []*placeNode, plan *flightPlan, criteria []namedCriterion) []float64 {
+	costs := make([]float64, len(criteria))
+	fs := newFlightState(plan)
+	for i := 1; i < len(route); i++ {
+		v := route[i-1].VertexTo(route[i])
+		for c, criterion := range criteria {
+			costs[c] += criterion.price(fs, v)
+		}
+		fs, _ = fs.TraverseStateHelper(v)
+	}
+	return costs
+}
+
+func formatCosts(costs []float64, criteria []namedCriterion) string {
+	parts := make([]string, len(criteria))
+	for i, c := range criteria {
+		parts[i] = fmt.Sprintf("%s %0.3f", c.name, costs[i])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// traverseCriteria finds the best route for a flight plan by -criteria,
+// whose first gives its cost.
+func (c *caseContext) traverseCriteria(ctx context.Context, plan *flightPlan, from, to *placeNode) ([]*placeNode, float64, bool, error) {
+	route, costs, ok, err := c.graph.TraverseCriteria(ctx, newFlightState(plan), from, to, prices(c.criteria), *maxStates)
+	if !ok {
+		return nil, 0.0, false, err
+	}
+	return route, costs[0], true, err
+}
+
+// flyPareto prints every route between two airports that no other beats by
+// both -pareto criteria, in increasing order of the first.
+func (c *caseContext) flyPareto(from, to *placeNode, profile *aircraftProfile, planeRange float64) {
+	if airport, reason := unusableEndpoint(from, to, profile); airport != nil {
+		fmt.Fprintf(c.out, "impossible (%s %s)\n", airport, reason)
+		return
+	}
+
+	plan := &flightPlan{planeRange, profile, c.minimizeTime, c.departHours, c.openingHours, false, c.diversionFor(profile), false, nil}
+	ctx, cancel := searchContext()
+	defer cancel()
+	front, err := c.graph.ParetoFront(ctx, newFlightState(plan), from, to, c.pareto[0].price, c.pareto[1].price, *maxStates)
+	if len(front) == 0 {
+		fmt.Fprintln(c.out, searchFailure(0.0, false, err))
+		return
+	}
+
+	if len(front) == 1 {
+		fmt.Fprintln(c.out, "1 route")
+	} else {
+		fmt.Fprintf(c.out, "%d routes\n", len(front))
+	}
+	for _, p := range front {
+		fmt.Fprintf(c.out, "    %s\n", formatCosts(p.Costs[:], c.pareto))
+		if *printRoute {
+			for _, n := range p.Path {
+				fmt.Fprintln(c.out, n.Record.String())
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(c.out, searchFailure(0.0, false, err))
+	}
+}
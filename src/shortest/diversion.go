@@ -0,0 +1,132 @@
+package main
+
+import (
+	g "graph"
+	"math"
+	"sphere"
+	"sync"
+)
+
+// Precision in km of the greatest distance to a diversion airport.
+const DIVERSION_TOLERANCE_KM = 0.1
+
+// diversionChecker enforces that every point along a vertex lies within a
+// profile's diversion limit of some airport suitable for it. Results are
+// cached per pair of nodes since the same vertices are tried by many flights,
+// which may be flown at the same time.
+type diversionChecker struct {
+	profile  *aircraftProfile
+	airports []*sphere.NVector
+	limitKm  float64
+	lock     sync.RWMutex // guards allowed
+	allowed  map[g.Link[place]]bool
+}
+
+func newDiversionChecker(airportNodes []*placeNode, profile *aircraftProfile) *diversionChecker {
+	d := &diversionChecker{profile: profile, airports: make([]*sphere.NVector, 0, len(airportNodes)), limitKm: profile.maxDiversionKm, allowed: make(map[g.Link[place]]bool)}
+	for _, n := range airportNodes {
+		if airport, isAirport := n.Record.airport(); isAirport && d.suits(airport) {
+			d.airports = append(d.airports, &airport.NVector)
+		}
+	}
+	return d
+}
+
+// suits reports whether an airport is one to divert to.
+func (d *diversionChecker) suits(airport *Airport) bool {
+	return !airport.closed && d.profile.canLandAt(airport)
+}
+
+// update adds and removes airports, forgetting what was worked out for the
+// vertices they could change, and returns the nodes of the graph those
+// vertices leave: all within a leg and the limit of an airport suitable.
+func (d *diversionChecker) update(graph *placeGraph, added, removed []*Airport, maxLegKm float64) (near []*placeNode) {
+	changed := make([]*sphere.NVector, 0)
+	for _, airport := range added {
+		if d.suits(airport) {
+			d.airports = append(d.airports, &airport.NVector)
+			changed = append(changed, &airport.NVector)
+		}
+	}
+	for _, airport := range removed {
+		if d.suits(airport) {
+			kept := d.airports[:0]
+			for _, location := range d.airports {
+				if location != &airport.NVector {
+					kept = append(kept, location)
+				}
+			}
+			d.airports = kept
+			changed = append(changed, &airport.NVector)
+		}
+	}
+
+	near = make([]*placeNode, 0)
+	isNear := make(map[*placeNode]bool)
+	reach := (maxLegKm + d.limitKm) / EARTH_RADIUS_KM
+	for _, n := range graph.Nodes() {
+		location := n.Record.Location()
+		for _, airport := range changed {
+			if location.AngleBetween(airport) <= reach {
+				near = append(near, n)
+				isNear[n] = true
+				break
+			}
+		}
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for link := range d.allowed {
+		if isNear[link.A] || !graph.Has(link.A) || !graph.Has(link.B) {
+			delete(d.allowed, link)
+		}
+	}
+	return
+}
+
+// gapKm returns the greatest distance from any point between two locations
+// to the nearest suitable airport. Airports too far from the arc to matter
+// within limitKm are skipped, so larger gaps are only known to exceed it.
+func (d *diversionChecker) gapKm(from, to *sphere.NVector, limitKm float64) float64 {
+	midpoint := from.Interpolate(to, 0.5)
+	reach := from.AngleBetween(to)/2 + limitKm/EARTH_RADIUS_KM
+	candidates := make([]*sphere.NVector, 0)
+	for _, airport := range d.airports {
+		if midpoint.AngleBetween(airport) <= reach {
+			candidates = append(candidates, airport)
+		}
+	}
+
+	gap, _ := sphere.MaxGapAlongArc(from, to, candidates, DIVERSION_TOLERANCE_KM/EARTH_RADIUS_KM)
+	return gap * EARTH_RADIUS_KM
+}
+
+func (d *diversionChecker) allows(v *placeVertex) bool {
+	link := g.Link[place]{A: v.From, B: v.To}
+	d.lock.RLock()
+	allowed, found := d.allowed[link]
+	d.lock.RUnlock()
+	if found {
+		return allowed
+	}
+
+	from := v.From.Record.Location()
+	to := v.To.Record.Location()
+	allowed = d.gapKm(&from, &to, d.limitKm) <= d.limitKm
+	d.lock.Lock()
+	d.allowed[link] = allowed
+	d.lock.Unlock()
+	return allowed
+}
+
+// legGapKm returns the greatest distance from any point on a leg to the
+// nearest suitable airport.
+func (d *diversionChecker) legGapKm(l leg) (gap float64) {
+	for i := 1; i < len(l.nodes); i++ {
+		from := l.nodes[i-1].Record.Location()
+		to := l.nodes[i].Record.Location()
+		gap = math.Max(gap, d.gapKm(&from, &to, math.Pi*EARTH_RADIUS_KM))
+	}
+	return
+}
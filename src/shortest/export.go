@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Command to write each case's network to a file for inspection with
+// standard graph tools: export FORMAT FILE [FROM TO AIRCRAFT], where FORMAT
+// is dot or graphml. The route of the flight, if one is given, is
+// highlighted. Cases after the first are written to files with the case
+// number before the extension.
+const EXPORT_COMMAND = "export"
+
+const (
+	EXPORT_DOT     = "dot"
+	EXPORT_GRAPHML = "graphml"
+)
+
+// caseFileName returns the file to export a case to.
+func caseFileName(fileName string, caseNumber int) string {
+	if caseNumber == 1 {
+		return fileName
+	}
+	ext := filepath.Ext(fileName)
+	return fmt.Sprintf("%s%d%s", strings.TrimSuffix(fileName, ext), caseNumber, ext)
+}
+
+// export writes the network in the given format to the given file,
+// highlighting the route of a flight if one is given.
+func (c *caseContext) export(args []string) {
+	if len(args) != 2 && len(args) != 5 {
+		panic(EXPORT_COMMAND + " needs a format and a file, and optionally a flight")
+	}
+
+	var write func(io.Writer, string, []*placeNode) error
+	switch args[0] {
+	case EXPORT_DOT:
+		write = c.graph.WriteDOT
+	case EXPORT_GRAPHML:
+		write = c.graph.WriteGraphML
+	default:
+		panic("unknown export format \"" + args[0] + "\"")
+	}
+
+	var route []*placeNode
+	if len(args) == 5 {
+		profile, planeRange := c.aircraftFor(args[4])
+		var failure string
+		if route, _, _, failure = c.fly(c.lookup(args[2]), c.lookup(args[3]), profile, planeRange); failure != "" {
+			fmt.Fprintf(c.out, "%s to %s is %s\n", args[2], args[3], failure)
+		}
+	}
+
+	file, err := os.Create(args[1])
+	if err != nil {
+		panic("couldn't create export file \"" + args[1] + "\"")
+	}
+	w := bufio.NewWriter(file)
+	err = write(w, "network", route)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		panic(fmt.Sprintf("couldn't write export file \"%s\" -- %s", args[1], err))
+	}
+	fmt.Fprintf(c.out, "wrote %s\n", args[1])
+}
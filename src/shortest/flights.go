@@ -0,0 +1,635 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	gsm "google_static_map"
+	"io"
+	"sphere"
+	"strconv"
+	"time"
+	"tour"
+	"unicode"
+)
+
+const (
+	ITINERARY_ARROW = "->"
+	SET_OPEN        = "["
+	SET_CLOSE       = "]"
+)
+
+var searchTimeout *time.Duration = flag.Duration("timeout", 0, "give up searching the network for a flight after this long (0 for never)")
+var maxStates *int = flag.Int("maxstates", 0, "give up searching the network for a flight after this many states (0 for no limit)")
+
+// settings gathered from the command line and side files, shared by all
+// cases.
+type settings struct {
+	profile      *aircraftProfile
+	profiles     map[string]*aircraftProfile
+	minimizeTime bool
+	departHours  float64
+	openingHours bool // flights wait for airports to open; see availability.go
+	closures     bool // some airport is closed
+	*airportData
+	criteria []namedCriterion // see criteria.go
+	pareto   []namedCriterion // two, or none
+}
+
+// caseContext holds one case's network of airports and where to write the
+// results of queries on it.
+type caseContext struct {
+	*settings
+	*network
+	out    io.Writer
+	groups map[string][]groupMember // see groups.go
+}
+
+// splitTokens breaks a line into whitespace separated fields, treating
+// double-quoted strings as single fields and set brackets as fields of
+// their own.
+func splitTokens(line string) (tokens []string, err error) {
+	tokens = make([]string, 0)
+	for i := 0; i < len(line); {
+		switch c := rune(line[i]); {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(line) && line[end] != '"'; end++ {
+				if line[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(line) {
+				return nil, fmt.Errorf("unterminated string in \"%s\"", line)
+			}
+			token, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token)
+			i = end + 1
+		case c == '[' || c == ']':
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			end := i
+			for end < len(line) && !unicode.IsSpace(rune(line[end])) && line[end] != '[' && line[end] != ']' {
+				end++
+			}
+			tokens = append(tokens, line[i:end])
+			i = end
+		}
+	}
+	return
+}
+
+// readFlightLine returns the fields of the next non-blank line.
+func readFlightLine(in *bufio.Reader) []string {
+	for {
+		line, err := in.ReadString('\n')
+		tokens, tokenErr := splitTokens(line)
+		if tokenErr != nil {
+			panic("could not read flight -- " + tokenErr.Error())
+		}
+		if len(tokens) > 0 {
+			return tokens
+		}
+		if err == io.EOF {
+			panic("missing flight line")
+		} else if err != nil {
+			panic("could not read flight -- " + err.Error())
+		}
+	}
+}
+
+// readFlightLines reads a case's flight lines, defining the groups named
+// among them as it goes.
+func (c *caseContext) readFlightLines(in *bufio.Reader, count int) [][]string {
+	lines := make([][]string, 0, count)
+	for len(lines) < count {
+		if tokens := readFlightLine(in); tokens[0] == GROUP_KEYWORD {
+			c.defineGroup(tokens[1:])
+		} else {
+			lines = append(lines, tokens)
+		}
+	}
+	return lines
+}
+
+// find looks up an airport by name, or by index when names are not read.
+func (c *caseContext) find(token string) (node *placeNode, found bool) {
+	if *readNames {
+		node = c.airportsByName[token]
+	} else if index, err := strconv.Atoi(token); err == nil && index > 0 && index < len(c.airportsByIndex) {
+		node = c.airportsByIndex[index]
+	}
+	return node, node != nil
+}
+
+// lookup is like find but insists the airport exists.
+func (c *caseContext) lookup(token string) *placeNode {
+	node, found := c.find(token)
+	if !found {
+		panic("unknown airport \"" + token + "\"")
+	}
+	return node
+}
+
+// aircraftFor interprets the last field of a flight line, which is either
+// a plane range to fly with the command line profile or the name of a
+// profile from the profiles file.
+func (c *caseContext) aircraftFor(aircraft string) (profile *aircraftProfile, planeRange float64) {
+	if _, err := fmt.Sscanf(aircraft, "%f", &planeRange); err == nil {
+		return c.profile, planeRange
+	}
+
+	profile, found := c.profiles[aircraft]
+	if !found {
+		panic("unknown aircraft profile \"" + aircraft + "\"")
+	}
+	return profile, profile.rangeKm
+}
+
+// unusableEndpoint returns whichever end of a flight the aircraft cannot use,
+// if either, and why.
+func unusableEndpoint(from, to *placeNode, profile *aircraftProfile) (*Airport, string) {
+	for _, n := range []*placeNode{from, to} {
+		if airport := n.Record.(*Airport); airport.closed {
+			return airport, "is closed"
+		} else if !profile.canLandAt(airport) {
+			return airport, "is unsuitable for " + profile.name
+		}
+	}
+	return nil, ""
+}
+
+// diversionFor returns the profile's diversion checker, or nil if it has no
+// diversion limit.
+func (c *caseContext) diversionFor(profile *aircraftProfile) (diversion *diversionChecker) {
+	if profile.maxDiversionKm > 0 {
+		c.diversionLock.Lock()
+		defer c.diversionLock.Unlock()
+		if diversion = c.diversionCheckers[profile]; diversion == nil {
+			diversion = newDiversionChecker(c.airports(), profile)
+			c.diversionCheckers[profile] = diversion
+		}
+	}
+	return
+}
+
+// fly finds the best route between two airports, or explains why there is
+// none.
+func (c *caseContext) fly(from, to *placeNode, profile *aircraftProfile, planeRange float64) (route []*placeNode, cost float64, plan *flightPlan, failure string) {
+	return c.flyAt(from, to, profile, planeRange, c.departHours)
+}
+
+// flyAt is fly departing at the given UTC time rather than the case's.
+func (c *caseContext) flyAt(from, to *placeNode, profile *aircraftProfile, planeRange, departHours float64) (route []*placeNode, cost float64, plan *flightPlan, failure string) {
+	if *verbose {
+		fmt.Fprintf(c.out, "from %s to %s with max plane range of %f\n", from.Record.(*Airport).String(), to.Record.(*Airport).String(), planeRange)
+	}
+
+	if airport, reason := unusableEndpoint(from, to, profile); airport != nil {
+		return nil, 0, nil, fmt.Sprintf("impossible (%s %s)", airport, reason)
+	}
+
+	plan = &flightPlan{planeRange, profile, c.minimizeTime, departHours, c.openingHours, false, c.diversionFor(profile), false, nil}
+	plan.bothEnds = *bidirectional && plan.rangeIsOnlyLimit() && c.criteria == nil
+	stats := observe(plan)
+	route, cost, ok, err := c.traverse(plan, from, to)
+	logStats(from.Record, to.Record, stats)
+	if failure = searchFailure(cost, ok, err); failure != "" {
+		if !ok && err == nil && c.closures {
+			failure = c.explainClosures(plan, from, to, failure)
+		}
+		return nil, 0, nil, failure
+	}
+	return route, cost, plan, ""
+}
+
+// explainClosures names, if it can, the closed airport that makes a flight
+// impossible: the first on the best route through closed airports.
+func (c *caseContext) explainClosures(plan *flightPlan, from, to *placeNode, failure string) string {
+	relaxed := *plan
+	relaxed.throughClosed, relaxed.observer = true, nil
+	route, _, ok, err := c.traverse(&relaxed, from, to)
+	if !ok || err != nil {
+		return failure
+	}
+	for _, n := range route {
+		if airport, isAirport := n.Record.airport(); isAirport && airport.closed {
+			return fmt.Sprintf("%s (%s is closed)", failure, airport)
+		}
+	}
+	return failure
+}
+
+// searchFailure explains why a search found no route, or returns "" if it
+// did.
+func searchFailure(cost float64, ok bool, err error) string {
+	if err != nil {
+		if ok {
+			return fmt.Sprintf("gave up (%s; best found %0.3f)", err, cost)
+		}
+		return fmt.Sprintf("gave up (%s)", err)
+	}
+	if !ok {
+		return "impossible"
+	}
+	return ""
+}
+
+// searchContext returns the context for searching the network for a
+// flight, which -timeout may limit.
+func searchContext() (context.Context, context.CancelFunc) {
+	if *searchTimeout > 0 {
+		return context.WithTimeout(context.Background(), *searchTimeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// traverse finds the best route for a flight plan: over the plan's overlay
+// or from both ends if asked to and the plan allows, otherwise by searching
+// the whole network from the start, by -criteria if given. Searches of the
+// network give up as -timeout and -maxstates say.
+func (c *caseContext) traverse(plan *flightPlan, from, to *placeNode) ([]*placeNode, float64, bool, error) {
+	if c.criteria != nil {
+		ctx, cancel := searchContext()
+		defer cancel()
+		return c.traverseCriteria(ctx, plan, from, to)
+	}
+	if *overlay && plan.rangeIsOnlyLimit() {
+		route, cost, ok := c.overlayFor(plan).route(from, to, plan.observer)
+		return route, cost, ok, nil
+	}
+
+	ctx, cancel := searchContext()
+	defer cancel()
+	if plan.bothEnds {
+		return c.graph.TraverseBidirectionalContext(ctx, newFlightState(plan), newMirroredFlightState(plan), from, to, *maxStates)
+	}
+	return c.graph.TraverseContext(ctx, newFlightState(plan), from, to, *maxStates)
+}
+
+// runFlightLine answers a flight line, which is either "FROM TO AIRCRAFT" or
+// an itinerary.
+func (c *caseContext) runFlightLine(tokens []string) {
+	if len(tokens) < 3 {
+		panic("flight line needs two airports and an aircraft")
+	}
+	profile, planeRange := c.aircraftFor(tokens[len(tokens)-1])
+	stops := tokens[:len(tokens)-1]
+
+	if len(stops) == 2 && (c.isGroup(stops[0]) || c.isGroup(stops[1])) {
+		if *minimumRange {
+			panic("groups cannot be flown with -minrange")
+		}
+		route, cost, plan, failure := c.flyGroups(stops[0], stops[1], profile, planeRange)
+		if failure != "" {
+			fmt.Fprintln(c.out, failure)
+		} else {
+			fmt.Fprintf(c.out, "%s -> %s\n", route[0].Record, route[len(route)-1].Record)
+			c.printFlight(route, cost, plan)
+		}
+		return
+	}
+	if len(stops) == 2 && stops[0] != SET_OPEN {
+		if *minimumRange {
+			c.flyMinimumRange(c.lookup(stops[0]), c.lookup(stops[1]), profile)
+			return
+		}
+		if c.pareto != nil {
+			c.flyPareto(c.lookup(stops[0]), c.lookup(stops[1]), profile, planeRange)
+			return
+		}
+		route, cost, plan, failure := c.fly(c.lookup(stops[0]), c.lookup(stops[1]), profile, planeRange)
+		if failure != "" {
+			fmt.Fprintln(c.out, failure)
+		} else {
+			c.printFlight(route, cost, plan)
+		}
+		return
+	}
+
+	c.flyItinerary(parseItinerary(stops), profile, planeRange)
+}
+
+// runFlightLines answers flight lines with the given number of workers,
+// writing each answer as soon as those before it are written.
+func (c *caseContext) runFlightLines(lines [][]string, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	answers := make([]bytes.Buffer, len(lines))
+	done := make([]chan bool, len(lines))
+	for i := range done {
+		done[i] = make(chan bool, 1)
+	}
+
+	next := make(chan int)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range next {
+				flight := *c
+				flight.out = &answers[i]
+				flight.runFlightLine(lines[i])
+				done[i] <- true
+			}
+		}()
+	}
+	go func() {
+		for i := range lines {
+			next <- i
+		}
+		close(next)
+	}()
+
+	for i := range lines {
+		<-done[i]
+		answers[i].WriteTo(c.out)
+	}
+}
+
+func (c *caseContext) printFlight(route []*placeNode, cost float64, plan *flightPlan) {
+	legs := splitLegs(route, plan.profile)
+	if c.minimizeTime {
+		distance := 0.0
+		for _, l := range legs {
+			distance += l.distanceKm
+		}
+		fmt.Fprintf(c.out, "%0.3f\n", distance)
+		printBlockTimes(c.out, legs, plan)
+	} else {
+		fmt.Fprintf(c.out, "%0.3f\n", cost)
+	}
+	if plan.profile.hasFuelModel() {
+		printFuelPlan(c.out, planRefuelling(legs, plan.profile))
+	}
+	if plan.diversion != nil {
+		for _, l := range legs {
+			fmt.Fprintf(c.out, "    %s -> %s: max diversion %0.1f km\n", l.from, l.to, plan.diversion.legGapKm(l))
+		}
+	}
+	for _, z := range c.constrainingZones(route) {
+		fmt.Fprintf(c.out, "    avoiding %s\n", z)
+	}
+	if c.criteria != nil {
+		fmt.Fprintf(c.out, "    %s\n", formatCosts(routeCosts(route, plan, c.criteria), c.criteria))
+	}
+	if *printRoute {
+		for _, n := range route {
+			fmt.Fprintln(c.out, n.Record.String())
+		}
+	}
+	if *googleMapsURL {
+		fmt.Fprintln(c.out, c.googleMap(route).Encode(true))
+	}
+}
+
+func (c *caseContext) googleMap(route []*placeNode) *gsm.Map {
+	gmap := gsm.NewMap(640, 640, 2)
+	airportsSeen := make(map[*Airport]bool)
+	flightPath := make([]sphere.NVector, 0, len(route))
+	for _, n := range route {
+		if airport, isAirport := n.Record.airport(); isAirport {
+			airportsSeen[airport] = true
+			lat, lon := airport.NVector.ToLatLonDegrees()
+			gmap.AddMarker(gsm.NewPoint(lat, lon))
+			flightPath = append(flightPath, airport.NVector)
+		} else if intersection, isIntersection := n.Record.(*AirportIntersection); isIntersection {
+			airportsSeen[intersection.airports[0]] = true
+			airportsSeen[intersection.airports[1]] = true
+			// lat, lon := intersection.NVector.ToLatLonDegrees()
+			// gmap.AddMarker(gsm.NewPoint(lat, lon))
+			flightPath = append(flightPath, intersection.NVector)
+		}
+	}
+	for airport, _ := range airportsSeen {
+		pathPoints := airport.NVector.CircleOnSphere(EARTH_RADIUS_KM, c.maxRadiusKm, 33)
+		polyLine := gsm.NewPolyLine()
+		polyLine.ClosePath = true
+		polyLine.SetWeight(1)
+		polyLine.SetColor("0x0000ffff")
+		polyLine.SetFillColor("0x8080ff40")
+		for _, pp := range pathPoints {
+			lat, lon := pp.ToLatLonDegrees()
+			polyLine.AddPointLatLon(lat, lon)
+		}
+		gmap.AddPath(polyLine)
+	}
+	flightPathPolyLine := makePolyLine(flightPath)
+	flightPathPolyLine.SetWeight(1)
+	flightPathPolyLine.SetColor("0xff0000ff")
+	gmap.AddPath(flightPathPolyLine)
+	return gmap
+}
+
+// itinerary
+
+// An itinerary is a list of stages, each either a single airport or a set
+// of airports to visit in whatever order is cheapest. Sets must be
+// separated by single airports, and cannot come first.
+type stage []string
+
+// parseItinerary reads stages separated by arrows, where a set of
+// airports is enclosed in brackets, e.g.
+//
+//	"ANC" -> [ "BRW" "BET" "OME" ] -> "ANC"
+func parseItinerary(tokens []string) (stages []stage) {
+	stages = make([]stage, 0)
+	expectStage := true
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if !expectStage {
+			if token != ITINERARY_ARROW {
+				panic("expected \"" + ITINERARY_ARROW + "\" in itinerary before \"" + token + "\"")
+			}
+			expectStage = true
+			continue
+		}
+
+		if token == SET_OPEN {
+			set := make(stage, 0)
+			for i++; i < len(tokens) && tokens[i] != SET_CLOSE; i++ {
+				set = append(set, tokens[i])
+			}
+			if i == len(tokens) || len(set) == 0 {
+				panic("unterminated or empty airport set in itinerary")
+			}
+			if len(stages) == 0 || len(stages[len(stages)-1]) != 1 {
+				panic("airport sets in an itinerary must follow a single airport")
+			}
+			stages = append(stages, set)
+		} else if token == ITINERARY_ARROW || token == SET_CLOSE {
+			panic("misplaced \"" + token + "\" in itinerary")
+		} else {
+			stages = append(stages, stage{token})
+		}
+		expectStage = false
+	}
+	if expectStage || len(stages) < 2 {
+		panic("itinerary needs at least two stages")
+	}
+	return
+}
+
+// segment
+
+type segment struct {
+	from, to *placeNode
+	route    []*placeNode
+	cost     float64
+}
+
+// segmentCache remembers the routes found between pairs of airports for
+// one aircraft, since tours and itineraries ask for them repeatedly.
+type segmentCache struct {
+	c          *caseContext
+	profile    *aircraftProfile
+	planeRange float64
+	segments   map[[2]*placeNode]segment
+	failures   map[[2]*placeNode]string
+}
+
+func newSegmentCache(c *caseContext, profile *aircraftProfile, planeRange float64) *segmentCache {
+	return &segmentCache{c, profile, planeRange, make(map[[2]*placeNode]segment), make(map[[2]*placeNode]string)}
+}
+
+func (sc *segmentCache) fly(from, to *placeNode) (segment, string) {
+	key := [2]*placeNode{from, to}
+	if s, found := sc.segments[key]; found {
+		return s, ""
+	} else if failure, found := sc.failures[key]; found {
+		return s, failure
+	}
+
+	route, cost, _, failure := sc.c.fly(from, to, sc.profile, sc.planeRange)
+	if failure != "" {
+		sc.failures[key] = failure
+		return segment{}, failure
+	}
+	sc.segments[key] = segment{from, to, route, cost}
+	return sc.segments[key], ""
+}
+
+// flyAt is fly departing at the given UTC time, which only makes a
+// difference under opening hours. Only segments departing at the case's
+// departure time are remembered.
+func (sc *segmentCache) flyAt(from, to *placeNode, departHours float64) (segment, string) {
+	if !sc.c.openingHours || departHours == sc.c.departHours {
+		return sc.fly(from, to)
+	}
+	route, cost, _, failure := sc.c.flyAt(from, to, sc.profile, sc.planeRange, departHours)
+	if failure != "" {
+		return segment{}, failure
+	}
+	return segment{from, to, route, cost}, ""
+}
+
+// costMatrix returns the cost of flying between each pair of the given
+// airports, using tour.Infeasible where there is no route.
+func (sc *segmentCache) costMatrix(nodes []*placeNode) [][]float64 {
+	cost := make([][]float64, len(nodes))
+	for a := range nodes {
+		cost[a] = make([]float64, len(nodes))
+		for b := range nodes {
+			if a == b {
+				continue
+			}
+			if s, failure := sc.fly(nodes[a], nodes[b]); failure == "" {
+				cost[a][b] = s.cost
+			} else {
+				cost[a][b] = tour.Infeasible
+			}
+		}
+	}
+	return cost
+}
+
+// flyItinerary routes each segment of an itinerary, ordering any sets of
+// airports to minimize the total, and prints the total and each segment.
+// Sets are ordered by segment costs as if each departed at the departure
+// time, exactly unless there are too many airports for tour.ExactPath, when
+// the order is only heuristic. Under opening hours each segment flown
+// departs once the one before has arrived and turned around. When
+// minimizing time the total includes turnaround at each stop between
+// segments, which the segments' own times leave out.
+func (c *caseContext) flyItinerary(stages []stage, profile *aircraftProfile, planeRange float64) {
+	cache := newSegmentCache(c, profile, planeRange)
+
+	stops := make([]*placeNode, 0)
+	for i, st := range stages {
+		if len(st) == 1 {
+			stops = append(stops, c.lookup(st[0]))
+			continue
+		}
+
+		// order the set between the stop before it and the one after, if any
+		candidates := []*placeNode{stops[len(stops)-1]}
+		for _, name := range st {
+			candidates = append(candidates, c.lookup(name))
+		}
+		end := -1
+		if i+1 < len(stages) {
+			candidates = append(candidates, c.lookup(stages[i+1][0]))
+			end = len(candidates) - 1
+		}
+
+		cost := cache.costMatrix(candidates)
+		order, _, _, ok := tour.Path(cost, 0, end)
+		if !ok {
+			fmt.Fprintln(c.out, "impossible")
+			return
+		}
+		for _, index := range order[1:] {
+			if index != end {
+				stops = append(stops, candidates[index])
+			}
+		}
+	}
+
+	flown := make([]segment, 0, len(stops)-1)
+	turnarounds := 0.0
+	if c.minimizeTime {
+		turnarounds = float64(len(stops)-2) * profile.turnaroundMins / 60.0
+	}
+	total := turnarounds
+	departHours := c.departHours
+	for i := 1; i < len(stops); i++ {
+		s, failure := cache.flyAt(stops[i-1], stops[i], departHours)
+		if failure != "" {
+			fmt.Fprintf(c.out, "%s (%s -> %s)\n", failure, stops[i-1].Record, stops[i].Record)
+			return
+		}
+		flown = append(flown, s)
+		total += s.cost
+		// a segment's cost is its time whenever opening hours apply
+		departHours += s.cost + profile.turnaroundMins/60.0
+	}
+
+	fmt.Fprintln(c.out, c.formatCost(total))
+	for _, s := range flown {
+		fmt.Fprintf(c.out, "    %s -> %s: %s\n", s.from.Record, s.to.Record, c.formatCost(s.cost))
+		if *printRoute {
+			for _, n := range s.route {
+				fmt.Fprintln(c.out, n.Record.String())
+			}
+		}
+	}
+	if turnarounds > 0 {
+		fmt.Fprintf(c.out, "    turnarounds: %s\n", formatHours(turnarounds))
+	}
+}
+
+// formatCost shows a traversal cost as a distance or a block time.
+func (c *caseContext) formatCost(cost float64) string {
+	if c.minimizeTime {
+		return formatHours(cost)
+	}
+	return fmt.Sprintf("%0.3f", cost)
+}
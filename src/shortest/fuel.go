@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const NO_FUEL = "none"
+
+var fuelFileName *string = flag.String("fuel", "", "name of fuel availability file")
+var fuelPrice *float64 = flag.Float64("fuelprice", 1.0, "price per kg of fuel at airports not in the fuel file")
+
+// readFuelPrices reads lines of the form `"NAME" price`, where a price of
+// "none" marks an airport that sells no fuel. Airports without fuel are
+// recorded with a negative price. Blank lines and lines starting with '#'
+// are ignored.
+func readFuelPrices(fileName string) map[string]float64 {
+	in, err := os.Open(fileName)
+	if err != nil {
+		panic("couldn't open fuel file \"" + fileName + "\"")
+	}
+	defer func() { in.Close() }()
+
+	prices := make(map[string]float64)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var name, priceText string
+		_, err = fmt.Sscanf(line, "%q %s", &name, &priceText)
+		if err != nil {
+			panic("couldn't parse fuel line \"" + line + "\"")
+		}
+
+		if priceText == NO_FUEL {
+			prices[name] = -1.0
+		} else {
+			var price float64
+			_, err = fmt.Sscanf(priceText, "%f", &price)
+			if err != nil || price < 0 {
+				panic("bad fuel price in line \"" + line + "\"")
+			}
+			prices[name] = price
+		}
+	}
+
+	return prices
+}
+
+// priceOnBoard returns the price of the fuel burned flying a vertex: that
+// of the airport where the aircraft last took on fuel, or nothing for fuel
+// it brought to an origin selling none.
+func (fs flightState) priceOnBoard(v *placeVertex) float64 {
+	if !fs.atOrigin {
+		return fs.fuelPrice
+	}
+	if origin, _ := v.From.Record.airport(); origin.hasFuel {
+		return origin.fuelPrice
+	}
+	return 0.0
+}
+
+// fuelCost returns what the fuel burned flying a vertex costs, as though
+// the aircraft bought at each stop selling fuel just what it burns before
+// the next. The search needs fuel to be modelled to go by it.
+func (fs flightState) fuelCost(v *placeVertex) float64 {
+	profile := fs.plan.profile
+	return profile.fuelKg(profile.vertexAirborneHours(v)) * fs.priceOnBoard(v)
+}
+
+// refuel
+
+type refuel struct {
+	airport *Airport
+	kg      float64
+}
+
+// planRefuelling decides how much to buy at each stop along an already
+// feasible route so as to minimize fuel cost. At each stop it buys just
+// enough to reach the next cheaper stop within reach, or fills up if there
+// is none. The aircraft is assumed to arrive at the origin with only its
+// reserve, unless the origin sells no fuel, in which case it departs full.
+//
+// Searching by the "fuelcost" criterion chooses the route by price, though
+// by fuelCost, which never carries fuel past a stop selling it. The plan
+// for the route found costs no more than that, as it could buy the same.
+func planRefuelling(legs []leg, profile *aircraftProfile) (plan []refuel) {
+	plan = make([]refuel, 0)
+	usable := profile.tankKg - profile.reserveFuelKg()
+
+	needs := make([]float64, len(legs))
+	for i, l := range legs {
+		needs[i] = profile.fuelKg(profile.legHours(l.airKm))
+	}
+
+	onboard := 0.0
+	if len(legs) > 0 && !legs[0].from.hasFuel {
+		onboard = usable
+	}
+
+	for i, l := range legs {
+		if l.from.hasFuel {
+			target := usable
+			needed := 0.0
+			for j := i; j < len(legs); j++ {
+				needed += needs[j]
+				if needed > usable {
+					break
+				}
+				if j+1 == len(legs) {
+					target = needed
+				} else if next := legs[j+1].from; next.hasFuel && next.fuelPrice < l.from.fuelPrice {
+					target = needed
+					break
+				}
+			}
+
+			if target > onboard {
+				plan = append(plan, refuel{l.from, target - onboard})
+				onboard = target
+			}
+		}
+
+		onboard -= needs[i]
+	}
+
+	return
+}
+
+func printFuelPlan(out io.Writer, plan []refuel) {
+	total := 0.0
+	for _, r := range plan {
+		cost := r.kg * r.airport.fuelPrice
+		total += cost
+		fmt.Fprintf(out, "    refuel %0.1f kg at %s for %0.2f\n", r.kg, r.airport, cost)
+	}
+	fmt.Fprintf(out, "    fuel cost: %0.2f\n", total)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	g "graph"
+	"strconv"
+	"strings"
+)
+
+// A group line among a case's flight lines names a set of airports that
+// later two-airport flight lines can fly from or to, taking whichever pair
+// is best:
+//
+//	group NAME AIRPORT [+COST] [AIRPORT [+COST] ...]
+//
+// A cost after an airport is added to flights from it, or to them, e.g.,
+// the cost of getting there by road, in the units of the flights' cost.
+// Group lines are not counted among the case's flight lines.
+const GROUP_KEYWORD = "group"
+
+type groupMember struct {
+	node   *placeNode
+	offset float64
+}
+
+// defineGroup interprets the rest of a group line.
+func (c *caseContext) defineGroup(args []string) {
+	if len(args) < 2 {
+		panic(GROUP_KEYWORD + " needs a name and at least one airport")
+	}
+	name := args[0]
+	if _, found := c.find(name); found {
+		panic("group \"" + name + "\" has the name of an airport")
+	}
+
+	members := make([]groupMember, 0)
+	for _, token := range args[1:] {
+		if !strings.HasPrefix(token, "+") {
+			members = append(members, groupMember{c.lookup(token), 0.0})
+			continue
+		}
+		offset, err := strconv.ParseFloat(token[1:], 64)
+		if err != nil || offset < 0 || len(members) == 0 {
+			panic("misplaced or bad cost \"" + token + "\" in group \"" + name + "\"")
+		}
+		members[len(members)-1].offset = offset
+	}
+	c.groups[name] = members
+}
+
+// leaveGroups takes a removed airport out of every group it was in, since
+// its node's id goes to another node. Groups left empty remain.
+func (c *caseContext) leaveGroups(node *placeNode) {
+	for name, members := range c.groups {
+		kept := make([]groupMember, 0, len(members))
+		for _, m := range members {
+			if m.node != node {
+				kept = append(kept, m)
+			}
+		}
+		c.groups[name] = kept
+	}
+}
+
+func (c *caseContext) isGroup(token string) bool {
+	_, found := c.groups[token]
+	return found
+}
+
+// members returns the airports of a group, or the airport of that name.
+func (c *caseContext) members(token string) []groupMember {
+	if members, found := c.groups[token]; found {
+		return members
+	}
+	return []groupMember{{c.lookup(token), 0.0}}
+}
+
+// usableMembers returns the members of a group the aircraft can use.
+func usableMembers(members []groupMember, profile *aircraftProfile) (usable []groupMember) {
+	for _, m := range members {
+		if airport := m.node.Record.(*Airport); !airport.closed && profile.canLandAt(airport) {
+			usable = append(usable, m)
+		}
+	}
+	return
+}
+
+// endpoints returns members of a group as the ends of a search.
+func endpoints(members []groupMember) []g.Endpoint[place] {
+	ends := make([]g.Endpoint[place], len(members))
+	for i, m := range members {
+		ends[i] = g.Endpoint[place]{Node: m.node, Offset: m.offset}
+	}
+	return ends
+}
+
+// flyGroups finds the best route from any airport of one group to any of
+// another, either of which may be a single airport, or explains why there
+// is none. The search is always of the whole network from the start, as
+// neither -bidi nor -overlay applies.
+func (c *caseContext) flyGroups(fromGroup, toGroup string, profile *aircraftProfile, planeRange float64) (route []*placeNode, cost float64, plan *flightPlan, failure string) {
+	from, to := usableMembers(c.members(fromGroup), profile), usableMembers(c.members(toGroup), profile)
+	for i, members := range [][]groupMember{from, to} {
+		if len(members) == 0 {
+			return nil, 0, nil, fmt.Sprintf("impossible (no airport of %s is usable)", []string{fromGroup, toGroup}[i])
+		}
+	}
+
+	plan = &flightPlan{planeRange, profile, c.minimizeTime, c.departHours, c.openingHours, false, c.diversionFor(profile), false, nil}
+	stats := observe(plan)
+	ctx, cancel := searchContext()
+	defer cancel()
+	route, cost, ok, err := c.graph.TraverseMulti(ctx, newFlightState(plan), endpoints(from), endpoints(to), *maxStates)
+	logStats(fromGroup, toGroup, stats)
+	if failure = searchFailure(cost, ok, err); failure != "" {
+		return nil, 0, nil, failure
+	}
+	return route, cost, plan, ""
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sphere"
+	"strconv"
+)
+
+// Command to edit and query the first case from standard input, which takes
+// lines of the form
+//
+//	add LON LAT ["NAME" ...]
+//	remove AIRPORT
+//	route FLIGHT-LINE
+//	report AIRCRAFT
+//	export FORMAT FILE [FROM TO AIRCRAFT]
+//	group NAME AIRPORT [+COST] ...
+const INTERACTIVE_COMMAND = "interactive"
+
+const (
+	ADD_COMMAND    = "add"
+	REMOVE_COMMAND = "remove"
+	ROUTE_COMMAND  = "route"
+)
+
+// interact runs commands until the input is exhausted. A bad command is
+// reported and the next one read.
+func (c *caseContext) interact(in *bufio.Reader) {
+	for {
+		line, err := in.ReadString('\n')
+		if tokens, tokenErr := splitTokens(line); tokenErr != nil {
+			fmt.Fprintln(c.out, tokenErr)
+		} else if len(tokens) > 0 {
+			c.runCommand(tokens)
+		}
+
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			panic("could not read command -- " + err.Error())
+		}
+	}
+}
+
+func (c *caseContext) runCommand(tokens []string) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintln(c.out, r)
+		}
+	}()
+
+	switch tokens[0] {
+	case ADD_COMMAND:
+		c.addCommand(tokens[1:])
+	case REMOVE_COMMAND:
+		if len(tokens) != 2 {
+			panic(REMOVE_COMMAND + " needs one airport")
+		}
+		node := c.lookup(tokens[1])
+		c.removeAirport(node)
+		c.leaveGroups(node)
+		fmt.Fprintf(c.out, "removed %s\n", node.Record)
+	case ROUTE_COMMAND:
+		c.runFlightLine(tokens[1:])
+	case REPORT_COMMAND:
+		c.report(tokens[1:])
+	case EXPORT_COMMAND:
+		c.export(tokens[1:])
+	case GROUP_KEYWORD:
+		c.defineGroup(tokens[1:])
+		fmt.Fprintf(c.out, "defined %s\n", tokens[1])
+	default:
+		panic("unknown command \"" + tokens[0] + "\"")
+	}
+}
+
+func (c *caseContext) addCommand(args []string) {
+	if len(args) < 2 {
+		panic(ADD_COMMAND + " needs a longitude and latitude")
+	}
+	lon, err1 := strconv.ParseFloat(args[0], 64)
+	lat, err2 := strconv.ParseFloat(args[1], 64)
+	if err1 != nil || err2 != nil {
+		panic("couldn't read lon-lat")
+	}
+
+	index := len(c.airportsByIndex)
+	names := args[2:]
+	if !*readNames {
+		names = []string{fmt.Sprintf("Airport %d", index)}
+	} else if len(names) == 0 {
+		panic(ADD_COMMAND + " needs a name")
+	}
+	for _, name := range names {
+		if _, found := c.airportsByName[name]; found {
+			panic("there is already an airport \"" + name + "\"")
+		}
+	}
+
+	c.addAirport(c.newAirport(sphere.NewNVectorFromLatLongDeg(lat, lon), names), names)
+	fmt.Fprintf(c.out, "added %s as %d\n", names[0], index)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+)
+
+// Slack added to a minimum range so that summing a hop's vertices in a
+// different order cannot make it fall just short.
+const RANGE_SLACK_KM = 0.000001
+
+var minimumRange *bool = flag.Bool("minrange", false, "print the smallest range that makes each two-airport flight possible, ignoring the aircraft's own")
+
+// refuelStop reports whether an aircraft may land at a node and leave with
+// its full range again, counting closed airports only if throughClosed.
+func refuelStop(profile *aircraftProfile, throughClosed bool) func(*placeNode) bool {
+	return func(n *placeNode) bool {
+		airport, isAirport := n.Record.airport()
+		return isAirport && (!airport.closed || throughClosed) && profile.canLandAt(airport) &&
+			(!profile.hasFuelModel() || airport.hasFuel)
+	}
+}
+
+// maxFuelHopKm returns the longest hop the aircraft's tank allows between
+// stops, keeping its reserve, or infinity without the fuel model.
+func maxFuelHopKm(profile *aircraftProfile) float64 {
+	if !profile.hasFuelModel() {
+		return math.Inf(1)
+	}
+	hours := (profile.tankKg-profile.reserveFuelKg())/profile.burnKgPerHour - profile.climbDescentMins/60.0
+	return math.Max(0.0, hours*profile.cruiseSpeedKmh)
+}
+
+// usableVertex reports whether an aircraft may fly a vertex at all,
+// whatever its range, landing at closed airports only if throughClosed.
+func usableVertex(profile *aircraftProfile, diversion *diversionChecker, throughClosed bool) func(*placeVertex) bool {
+	return func(v *placeVertex) bool {
+		if airport, isAirport := v.To.Record.airport(); isAirport && ((airport.closed && !throughClosed) || !profile.canLandAt(airport)) {
+			return false
+		}
+		return diversion == nil || diversion.allows(v)
+	}
+}
+
+// flyMinimumRange prints the smallest range with which the aircraft can get
+// between two airports, then the best route with that range. Under the fuel
+// model, hops the tank cannot cover are left out, so the flight is
+// impossible when fuel rather than range is what limits it.
+func (c *caseContext) flyMinimumRange(from, to *placeNode, profile *aircraftProfile) {
+	if airport, reason := unusableEndpoint(from, to, profile); airport != nil {
+		fmt.Fprintf(c.out, "impossible (%s %s)\n", airport, reason)
+		return
+	}
+
+	// the ends are stops even without fuel, as the aircraft need not refuel
+	// to finish and leaves its origin full
+	refuels := refuelStop(profile, false)
+	isStop := func(n *placeNode) bool { return n == from || n == to || refuels(n) }
+	_, bottleneck, ok := c.graph.MinimaxPathWithin(from, to, isStop, usableVertex(profile, c.diversionFor(profile), false), profile.airKm, maxFuelHopKm(profile))
+	if !ok {
+		fmt.Fprintln(c.out, "impossible")
+		return
+	}
+	fmt.Fprintf(c.out, "minimum range %f\n", bottleneck)
+
+	route, cost, plan, failure := c.fly(from, to, profile, bottleneck+RANGE_SLACK_KM)
+	if failure != "" {
+		fmt.Fprintln(c.out, failure)
+	} else {
+		c.printFlight(route, cost, plan)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	g "graph"
+	ipolate "interpolate"
+	"log/slog"
+	"math"
+	"sphere"
+	"sync"
+)
+
+// network is one case's graph of airports and the intersections of their
+// range circles. Airports can be added and removed one at a time, which
+// only touches the airports within twice the radius of the one changed.
+// The graph is left frozen after each change, ready to be searched, with
+// only the nodes changed packed again, and the overlays and diversion
+// checkers worked out so far are brought up to date rather than dropped.
+type network struct {
+	graph               *placeGraph
+	maxRadiusKm         float64
+	circleRadiusKm      float64
+	circleEarthRadiusKm float64
+	airportsByIndex     []*placeNode // index 0 is unused and removed airports leave nil
+	airportsByName      map[string]*placeNode
+	airportRadiusNodes  map[*placeNode]*[]*placeNode // intersections on each airport's circle
+	diversionLock       sync.Mutex                   // guards diversionCheckers while flights are flown
+	diversionCheckers   map[*aircraftProfile]*diversionChecker
+	overlayLock         sync.Mutex // guards overlays while flights are flown
+	overlays            map[overlayKey]*airportOverlay
+	zoneBlocks          map[*placeNode][]zoneBlock // the zones that kept edges from each node, and to which others
+}
+
+// newPlaceGraph returns a graph whose vertices have attributes, including
+// one for each of windSpeeds, when winds or land are known, and none
+// otherwise.
+func newPlaceGraph() *placeGraph {
+	if winds == nil && land == nil {
+		return g.NewGraph[place, flightState]()
+	}
+	return g.NewGraphWithAttributes[place, flightState](ATTR_COUNT + len(windSpeeds))
+}
+
+func newNetwork(maxRadiusKm float64) *network {
+	radiusAngleRadians := maxRadiusKm / EARTH_RADIUS_KM
+	nw := &network{
+		graph:               newPlaceGraph(),
+		maxRadiusKm:         maxRadiusKm,
+		circleRadiusKm:      math.Sin(radiusAngleRadians) * EARTH_RADIUS_KM,
+		circleEarthRadiusKm: math.Cos(radiusAngleRadians) * EARTH_RADIUS_KM,
+		airportsByIndex:     make([]*placeNode, 1),
+		airportsByName:      make(map[string]*placeNode),
+		airportRadiusNodes:  make(map[*placeNode]*[]*placeNode),
+		diversionCheckers:   make(map[*aircraftProfile]*diversionChecker),
+		overlays:            make(map[overlayKey]*airportOverlay),
+		zoneBlocks:          make(map[*placeNode][]zoneBlock),
+	}
+
+	if *verbose {
+		slog.Info("circles", "radius", nw.circleRadiusKm, "earthRadius", nw.circleEarthRadiusKm)
+	}
+
+	return nw
+}
+
+// airports returns the airports currently in the network, by index.
+func (nw *network) airports() []*placeNode {
+	result := make([]*placeNode, 0, len(nw.airportsByIndex))
+	for _, n := range nw.airportsByIndex[1:] {
+		if n != nil {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// nearPair is an airport already in the network, or an earlier one in a
+// batch, whose circle overlaps a later one's.
+type nearPair struct {
+	earlier  int // among those in the network and then the batch
+	geometry *pairGeometry
+}
+
+// addAirports adds a batch of airports, as addAirport would one after
+// another, but shares the work with the given number of workers: first
+// measuring the pairs of airports, then, once the intersections are made in
+// order, measuring the links between them. Only making nodes and joining
+// links is done in order, so the graph is the same however many workers
+// there are.
+func (nw *network) addAirports(airports []*Airport, names [][]string, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// each airport's overlaps with those before it
+	nodes := nw.airports()
+	existing := len(nodes)
+	nodes = append(nodes, make([]*placeNode, len(airports))...)
+	earlierAirport := func(k int) *Airport {
+		if k < existing {
+			return nodes[k].Record.(*Airport)
+		}
+		return airports[k-existing]
+	}
+	overlaps := make([][]nearPair, len(airports))
+	inParallel(len(airports), workers, func(i int) {
+		for k := 0; k < existing+i; k++ {
+			if geometry := nw.measurePair(airports[i], earlierAirport(k)); geometry != nil {
+				overlaps[i] = append(overlaps[i], nearPair{k, geometry})
+			}
+		}
+	})
+
+	links := make([]link, 0)
+	for i, airport := range airports {
+		nodes[existing+i] = nw.newAirportNode(airport, names[i])
+		for _, pair := range overlaps[i] {
+			links = append(links, nw.pairLinks(nodes[existing+i], nodes[pair.earlier], pair.geometry)...)
+		}
+	}
+
+	inParallel(len(links), workers, func(i int) { links[i].measure() })
+	for i := range links {
+		links[i].apply(nw)
+	}
+
+	nw.changed(airports, nil)
+}
+
+// inParallel calls do for each of count indices, sharing them out among the
+// given number of workers in blocks.
+func inParallel(count, workers int, do func(i int)) {
+	const BLOCK = 64
+	blocks := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range blocks {
+				for i := start; i < start+BLOCK && i < count; i++ {
+					do(i)
+				}
+			}
+		}()
+	}
+	for start := 0; start < count; start += BLOCK {
+		blocks <- start
+	}
+	close(blocks)
+	wg.Wait()
+}
+
+// changed freezes the network after airports are added or removed and
+// brings what was worked out from the old set of airports up to date:
+// first the diversion checkers, which the overlays use, then the hops of
+// the overlays near the nodes that changed or that a checker now judges
+// differently. Contracted overlays are left to be contracted again when
+// next flown over; see overlayFor.
+func (nw *network) changed(added, removed []*Airport) {
+	near := nw.graph.Thawed()
+	nw.graph.Freeze()
+	for _, diversion := range nw.diversionCheckers {
+		near = append(near, diversion.update(nw.graph, added, removed, 2*nw.maxRadiusKm)...)
+	}
+	for _, o := range nw.overlays {
+		o.Update(near)
+		o.hierarchy = nil
+	}
+}
+
+// newAirportNode gives an airport the next index and files it under each of
+// its names, without connecting it.
+func (nw *network) newAirportNode(airport *Airport, names []string) *placeNode {
+	node := nw.graph.NewNode(airport)
+	nw.airportsByIndex = append(nw.airportsByIndex, node)
+	for _, name := range names {
+		nw.airportsByName[name] = node
+	}
+	sl := make([]*placeNode, 0)
+	nw.airportRadiusNodes[node] = &sl
+	return node
+}
+
+// addAirport gives an airport the next index, files it under each of its
+// names and connects it to the airports already present.
+func (nw *network) addAirport(airport *Airport, names []string) *placeNode {
+	node := nw.newAirportNode(airport, names)
+	for _, other := range nw.airportsByIndex[1 : len(nw.airportsByIndex)-1] {
+		if other != nil {
+			nw.connectAirports(node, other)
+		}
+	}
+
+	nw.changed([]*Airport{airport}, nil)
+	return node
+}
+
+// removeAirport takes an airport, its circle's intersections and all their
+// vertices out of the network. Its index is not reused.
+func (nw *network) removeAirport(node *placeNode) {
+	airport := node.Record.(*Airport)
+	doomed := append([]*placeNode{node}, *nw.airportRadiusNodes[node]...)
+	delete(nw.airportRadiusNodes, node)
+
+	for _, midpoints := range nw.airportRadiusNodes {
+		kept := (*midpoints)[:0]
+		for _, n := range *midpoints {
+			if intersection := n.Record.(*AirportIntersection); intersection.airports[0] != airport && intersection.airports[1] != airport {
+				kept = append(kept, n)
+			}
+		}
+		*midpoints = kept
+	}
+
+	nw.graph.RemoveNodes(doomed...)
+	for _, n := range doomed {
+		nw.forgetBlocks(n)
+	}
+
+	for i, n := range nw.airportsByIndex {
+		if n == node {
+			nw.airportsByIndex[i] = nil
+		}
+	}
+	for name, n := range nw.airportsByName {
+		if n == node {
+			delete(nw.airportsByName, name)
+		}
+	}
+
+	nw.changed(nil, []*Airport{airport})
+}
+
+// pairGeometry is what connecting two airports needs to know about where
+// they lie, which can be worked out without touching the graph.
+type pairGeometry struct {
+	swapped       bool // the second airport comes first in the pair
+	distance      float64
+	intersections [2]*sphere.NVector // where the circles meet
+}
+
+// measurePair returns the geometry of two airports, or nil if their circles
+// do not overlap.
+func (nw *network) measurePair(airport1, airport2 *Airport) *pairGeometry {
+	geometry := &pairGeometry{}
+	if !airport1.NVector.LessThan(&airport2.NVector) {
+		airport1, airport2 = airport2, airport1
+		geometry.swapped = true
+	}
+
+	airportAngle := airport1.NVector.AngleBetween(&airport2.NVector)
+	geometry.distance = airportAngle * EARTH_RADIUS_KM
+	if *verbose {
+		slog.Info("measure airports", "airport1", airport1.name, "airport2", airport2.name, "km", geometry.distance, "overlap", geometry.distance <= 2*nw.maxRadiusKm)
+	}
+	if geometry.distance > 2*nw.maxRadiusKm {
+		return nil
+	}
+
+	perp := airport1.NVector.CrossProduct(&airport2.NVector).Normalize()
+	toMeetV1 := perp.CrossProduct(&airport1.NVector)
+	toMeetV2 := airport2.NVector.CrossProduct(perp)
+
+	discCenter1 := airport1.ScaleTo(nw.circleEarthRadiusKm)
+	discCenter2 := airport2.ScaleTo(nw.circleEarthRadiusKm)
+	discMeetDistance := math.Tan(airportAngle/2) * nw.circleEarthRadiusKm
+
+	discMeetPoint := discCenter1.Add(toMeetV1.ScaleTo(discMeetDistance))
+	discMeetPointAlt := discCenter2.Add(toMeetV2.ScaleTo(discMeetDistance))
+
+	if *verbose {
+		slog.Info("circles meet", "airport1", airport1.name, "airport2", airport2.name, "toMeet", toMeetV1.String(), "meetPoint", discMeetPoint.String(), "meetPointAlt", discMeetPointAlt.String())
+	}
+
+	sphereRadiusFunc := func(in float64) float64 {
+		return perp.ScaleBy(in).Add(discMeetPoint).Magnitude()
+	}
+
+	intersectionFactor1, ok1 := ipolate.Interpolator(0.0, nw.circleRadiusKm, EARTH_RADIUS_KM, INTERPOLATION_PRECISION, sphereRadiusFunc)
+	intersectionFactor2, ok2 := ipolate.Interpolator(0.0, -nw.circleRadiusKm, EARTH_RADIUS_KM, INTERPOLATION_PRECISION, sphereRadiusFunc)
+
+	if ok1 && ok2 {
+		geometry.intersections[0] = perp.ScaleBy(intersectionFactor1).Add(discMeetPoint)
+		geometry.intersections[1] = perp.ScaleBy(intersectionFactor2).Add(discMeetPoint)
+	} else if ok1 || ok2 {
+		panic("only one point found with two nearby airports")
+	} else {
+		panic("no points found with two nearby airports")
+	}
+
+	return geometry
+}
+
+// connectAirports links two airports directly and through the points where
+// their circles meet, provided the circles overlap.
+func (nw *network) connectAirports(airport1Node, airport2Node *placeNode) {
+	geometry := nw.measurePair(airport1Node.Record.(*Airport), airport2Node.Record.(*Airport))
+	if geometry != nil {
+		for _, l := range nw.pairLinks(airport1Node, airport2Node, geometry) {
+			l.measure()
+			l.apply(nw)
+		}
+	}
+}
+
+// pairLinks adds the intersection nodes for two airports whose circles
+// overlap and returns the links to make to them and between the airports,
+// in the order they are to be joined.
+func (nw *network) pairLinks(airport1Node, airport2Node *placeNode, geometry *pairGeometry) []link {
+	if geometry.swapped {
+		airport1Node, airport2Node = airport2Node, airport1Node
+	}
+	airport1 := airport1Node.Record.(*Airport)
+	airport2 := airport2Node.Record.(*Airport)
+	slog.Debug("connect airports", "airport1", airport1.name, "airport2", airport2.name)
+	midpoints1 := nw.airportRadiusNodes[airport1Node]
+	midpoints2 := nw.airportRadiusNodes[airport2Node]
+
+	links := []link{{n1: airport1Node, n2: airport2Node, distance: geometry.distance}}
+
+	airportPair := [2]*Airport{airport1, airport2}
+
+	location1 := AirportIntersection{*geometry.intersections[0], airportPair}
+	node1 := nw.graph.NewNode(&location1)
+
+	location2 := AirportIntersection{*geometry.intersections[1], airportPair}
+	node2 := nw.graph.NewNode(&location2)
+
+	links = append(links, routeLinks(airport1Node, node1, *midpoints1, nw.maxRadiusKm)...)
+	links = append(links, routeLinks(airport1Node, node2, *midpoints1, nw.maxRadiusKm)...)
+
+	links = append(links, routeLinks(airport2Node, node1, *midpoints2, nw.maxRadiusKm)...)
+	links = append(links, routeLinks(airport2Node, node2, *midpoints2, nw.maxRadiusKm)...)
+
+	*midpoints1 = append(*midpoints1, node1, node2)
+	*midpoints2 = append(*midpoints2, node1, node2)
+	return links
+}
+
+// airportData holds what the side files say about airports, by name.
+type airportData struct {
+	runways      map[string][]runway
+	availability map[string]availability
+	fuelPrices   map[string]float64
+}
+
+// newAirport makes an airport at a location, taking its details from
+// whichever of its names the side files list.
+func (d *airportData) newAirport(location *sphere.NVector, names []string) *Airport {
+	airport := &Airport{*location, names[0], true, *fuelPrice, availability{}, nil}
+	for _, name := range names {
+		if runways, listed := d.runways[name]; listed {
+			airport.runways = runways
+		}
+		if avail, listed := d.availability[name]; listed {
+			airport.availability = avail
+		}
+		if price, listed := d.fuelPrices[name]; listed {
+			airport.hasFuel = price >= 0
+			airport.fuelPrice = price
+		}
+	}
+	return airport
+}
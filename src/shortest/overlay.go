@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	g "graph"
+)
+
+var overlay *bool = flag.Bool("overlay", false, "fly two-airport flights over direct airport-to-airport legs worked out once for each aircraft and range")
+var contract *bool = flag.Bool("ch", false, "with -overlay, contract each overlay so that flights over it are found faster")
+
+// airportOverlay collapses the intersections of a network into the legs
+// that an aircraft with a given range could fly between two landings, each
+// the shortest way between its airports that passes over no other airport
+// it could use.
+type airportOverlay struct {
+	*g.Overlay[place, flightState]
+	hierarchy *g.Hierarchy[place, flightState] // nil until contracted, and again once the overlay changes
+	plan      flightPlan                       // for searching the overlay
+}
+
+type overlayKey struct {
+	profile       *aircraftProfile
+	planeRange    float64
+	throughClosed bool
+}
+
+// overlayFor returns the overlay for a plan's aircraft and range, and for
+// whether it lands at closed airports, working it out the first time it is
+// asked for. Under -ch it is contracted whenever it is asked for after being
+// made or changed, so that a run of edits to the network pays for only one
+// contraction.
+func (c *caseContext) overlayFor(plan *flightPlan) *airportOverlay {
+	c.overlayLock.Lock()
+	defer c.overlayLock.Unlock()
+
+	key := overlayKey{plan.profile, plan.fullRange, plan.throughClosed}
+	o := c.overlays[key]
+	if o == nil {
+		o = &airportOverlay{plan: *plan}
+		o.Overlay = c.graph.Overlay(refuelStop(plan.profile, plan.throughClosed), usableVertex(plan.profile, plan.diversion, plan.throughClosed), plan.profile.airKm, plan.fullRange)
+		// every leg of the overlay is already flyable and lands at an
+		// airport, where the range starts afresh
+		o.plan.diversion = nil
+		o.plan.bothEnds = false
+		c.overlays[key] = o
+	}
+	if *contract && o.hierarchy == nil {
+		o.hierarchy = o.Contract()
+	}
+	return o
+}
+
+// route finds the shortest route between two airports over the overlay and
+// lays it back onto the network. Searches of a contracted overlay are timed
+// by the observer, if any, but not stepped through.
+func (o *airportOverlay) route(from, to *placeNode, observer g.TraverseObserver[place]) (route []*placeNode, cost float64, ok bool) {
+	var legs []*placeNode
+	if o.hierarchy != nil {
+		if observer != nil {
+			observer.Started([]*placeNode{o.NodeFor(from)}, []*placeNode{o.NodeFor(to)})
+		}
+		legs, cost, ok = o.hierarchy.Path(o.NodeFor(from), o.NodeFor(to))
+		if observer != nil {
+			observer.Finished(ok)
+		}
+	} else {
+		plan := o.plan
+		plan.observer = observer
+		legs, cost, ok = o.Traverse(newFlightState(&plan), o.NodeFor(from), o.NodeFor(to))
+	}
+	if !ok {
+		return nil, 0.0, false
+	}
+	return o.Expand(legs), cost, true
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sphere"
+	"strings"
+)
+
+const (
+	OPTIMIZE_DISTANCE = "distance"
+	OPTIMIZE_TIME     = "time"
+
+	DEFAULT_CRUISE_KMH         = 800.0
+	DEFAULT_CLIMB_DESCENT_MINS = 20.0
+	DEFAULT_TURNAROUND_MINS    = 45.0
+	DEFAULT_RESERVE_MINS       = 45.0
+
+	DIVERSION_MINUTES = "min"
+
+	DEFAULT_PROFILE_NAME = "the default aircraft"
+)
+
+var optimize *string = flag.String("opt", OPTIMIZE_DISTANCE, "quantity to minimize (\""+OPTIMIZE_DISTANCE+"\" or \""+OPTIMIZE_TIME+"\")")
+var cruiseSpeed *float64 = flag.Float64("cruise", DEFAULT_CRUISE_KMH, "cruise speed in km/h")
+var climbDescent *float64 = flag.Float64("climb", DEFAULT_CLIMB_DESCENT_MINS, "climb and descent allowance per leg in minutes")
+var turnaround *float64 = flag.Float64("turnaround", DEFAULT_TURNAROUND_MINS, "turnaround time per intermediate stop in minutes")
+var burnRate *float64 = flag.Float64("burn", 0.0, "fuel burn in kg/h; enables the fuel model when positive")
+var tankCapacity *float64 = flag.Float64("tank", 0.0, "usable fuel capacity in kg")
+var reserve *float64 = flag.Float64("reserve", DEFAULT_RESERVE_MINS, "mandatory fuel reserve in minutes")
+var profileFileName *string = flag.String("profiles", "", "name of aircraft profiles file")
+
+// aircraftProfile
+
+type aircraftProfile struct {
+	name             string
+	rangeKm          float64 // zero when given by each flight line
+	cruiseSpeedKmh   float64
+	climbDescentMins float64
+	turnaroundMins   float64
+	burnKgPerHour    float64 // zero when fuel is not modelled
+	tankKg           float64
+	reserveMins      float64
+	minRunwayFt      float64 // zero when unconstrained, as are the rest
+	maxCrosswindKmh  float64
+	maxDiversionKm   float64
+	windColumn       int // vertex attribute of its still-air distances, or 0 for vertex costs
+}
+
+func newAircraftProfileFromFlags() *aircraftProfile {
+	if *cruiseSpeed <= 0 {
+		panic("cruise speed must be positive")
+	}
+	p := &aircraftProfile{DEFAULT_PROFILE_NAME, 0.0, *cruiseSpeed, *climbDescent, *turnaround, *burnRate, *tankCapacity, *reserve, 0.0, 0.0, 0.0, 0}
+	if p.hasFuelModel() && p.tankKg <= p.reserveFuelKg() {
+		panic("fuel tank cannot hold more than the reserve")
+	}
+	return p
+}
+
+// readProfiles reads lines of the form
+//
+//	"NAME" rangeKm cruiseKmh minRunwayFt maxCrosswindKmh maxDiversion
+//
+// where a zero runway, crosswind or diversion figure leaves that aspect
+// unconstrained. The diversion limit is in km, or in minutes at cruise when
+// suffixed with "min". Climb, turnaround and fuel figures are taken from base.
+// Blank lines and lines starting with '#' are ignored.
+func readProfiles(fileName string, base *aircraftProfile) map[string]*aircraftProfile {
+	in, err := os.Open(fileName)
+	if err != nil {
+		panic("couldn't open profiles file \"" + fileName + "\"")
+	}
+	defer func() { in.Close() }()
+
+	result := make(map[string]*aircraftProfile)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p := *base
+		var diversion string
+		_, err = fmt.Sscanf(line, "%q %f %f %f %f %s", &p.name, &p.rangeKm, &p.cruiseSpeedKmh, &p.minRunwayFt, &p.maxCrosswindKmh, &diversion)
+		if err != nil {
+			panic("couldn't parse profile line \"" + line + "\"")
+		}
+		if minutes := strings.TrimSuffix(diversion, DIVERSION_MINUTES); minutes != diversion {
+			_, err = fmt.Sscanf(minutes, "%f", &p.maxDiversionKm)
+			p.maxDiversionKm *= p.cruiseSpeedKmh / 60.0
+		} else {
+			_, err = fmt.Sscanf(diversion, "%f", &p.maxDiversionKm)
+		}
+		if err != nil {
+			panic("bad diversion limit in profile line \"" + line + "\"")
+		}
+		if p.rangeKm <= 0 || p.cruiseSpeedKmh <= 0 {
+			panic("profile \"" + p.name + "\" needs a positive range and cruise speed")
+		}
+		result[p.name] = &p
+	}
+
+	return result
+}
+
+// assignWindColumns gives each profile whose cruise speed differs from
+// -cruise the vertex attribute holding still-air distances at its speed,
+// sharing them between profiles of the same speed, and returns the speeds
+// in attribute order.
+func assignWindColumns(profiles map[string]*aircraftProfile) (speeds []float64) {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	columns := make(map[float64]int)
+	for _, name := range names {
+		p := profiles[name]
+		if p.cruiseSpeedKmh == *cruiseSpeed {
+			continue
+		}
+		if columns[p.cruiseSpeedKmh] == 0 {
+			columns[p.cruiseSpeedKmh] = ATTR_COUNT + len(speeds)
+			speeds = append(speeds, p.cruiseSpeedKmh)
+		}
+		p.windColumn = columns[p.cruiseSpeedKmh]
+	}
+	return
+}
+
+// airKm returns how far the aircraft flies through the air over a vertex:
+// its cost unless the aircraft cruises through wind at a speed of its own,
+// for which the vertex has an attribute. Vertices without attributes, such
+// as an overlay's, already cost the aircraft's own air distance.
+func (p *aircraftProfile) airKm(v *placeVertex) float64 {
+	if attributes := v.Attributes(); p.windColumn != 0 && attributes != nil {
+		return attributes[p.windColumn]
+	}
+	return v.Cost
+}
+
+// canLandAt reports whether the airport has a runway long enough for the
+// aircraft and, when surface winds are known, one within its crosswind limit.
+func (p *aircraftProfile) canLandAt(a *Airport) bool {
+	if p.minRunwayFt <= 0 && p.maxCrosswindKmh <= 0 {
+		return true
+	}
+	if a.runways == nil {
+		return p.minRunwayFt <= 0 || !haveRunwayData
+	}
+
+	for _, rw := range a.runways {
+		if rw.lengthFt < p.minRunwayFt {
+			continue
+		}
+		if p.maxCrosswindKmh > 0 && surfaceWinds != nil && rw.headingDeg >= 0 {
+			lat, lon := a.NVector.ToLatLonDegrees()
+			u, v := surfaceWinds.At(lat, lon)
+			if crosswindKmh(u, v, rw.headingDeg) > p.maxCrosswindKmh {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// crosswindKmh returns the part of a wind blowing toward east u and north v
+// that is across a runway with the given true heading.
+func crosswindKmh(u, v, headingDeg float64) float64 {
+	heading := sphere.DegreesToRadians(headingDeg)
+	return math.Abs(u*math.Cos(heading) - v*math.Sin(heading))
+}
+
+func (p *aircraftProfile) hasFuelModel() bool {
+	return p.burnKgPerHour > 0
+}
+
+func (p *aircraftProfile) fuelKg(hours float64) float64 {
+	return hours * p.burnKgPerHour
+}
+
+func (p *aircraftProfile) reserveFuelKg() float64 {
+	return p.fuelKg(p.reserveMins / 60.0)
+}
+
+// flightHours returns the time spent airborne covering distanceKm at cruise.
+func (p *aircraftProfile) flightHours(distanceKm float64) float64 {
+	return distanceKm / p.cruiseSpeedKmh
+}
+
+// vertexAirborneHours returns the time in the air spent on a single vertex.
+// Climb and descent are charged on landing.
+func (p *aircraftProfile) vertexAirborneHours(v *placeVertex) (hours float64) {
+	hours = p.flightHours(p.airKm(v))
+	if _, landing := v.To.Record.airport(); landing {
+		hours += p.climbDescentMins / 60.0
+	}
+	return
+}
+
+// vertexHours returns the block time contributed by a single vertex, adding
+// turnaround on departing any airport but the origin.
+func (p *aircraftProfile) vertexHours(v *placeVertex, fromOrigin bool) (hours float64) {
+	hours = p.vertexAirborneHours(v)
+	if _, departing := v.From.Record.airport(); departing && !fromOrigin {
+		hours += p.turnaroundMins / 60.0
+	}
+	return
+}
+
+// legHours returns the time from takeoff to landing for a leg.
+func (p *aircraftProfile) legHours(distanceKm float64) float64 {
+	return p.flightHours(distanceKm) + p.climbDescentMins/60.0
+}
+
+func formatHours(hours float64) string {
+	minutes := int(hours*60.0 + 0.5)
+	return fmt.Sprintf("%d:%02d", minutes/60, minutes%60)
+}
+
+// leg
+
+type leg struct {
+	from, to   *Airport
+	nodes      []*placeNode
+	distanceKm float64 // over the ground
+	airKm      float64 // in still air, which differs when flying through wind
+}
+
+// splitLegs breaks a route flown by the given aircraft into the legs flown
+// between landings.
+func splitLegs(route []*placeNode, profile *aircraftProfile) (legs []leg) {
+	legs = make([]leg, 0)
+	if len(route) == 0 {
+		return
+	}
+
+	current := leg{route[0].Record.(*Airport), nil, []*placeNode{route[0]}, 0.0, 0.0}
+	for i := 1; i < len(route); i++ {
+		prev := route[i-1].Record.Location()
+		next := route[i].Record.Location()
+		current.distanceKm += prev.AngleBetween(&next) * EARTH_RADIUS_KM
+		current.airKm += profile.airKm(route[i-1].VertexTo(route[i]))
+		current.nodes = append(current.nodes, route[i])
+
+		if airport, isAirport := route[i].Record.(*Airport); isAirport {
+			current.to = airport
+			legs = append(legs, current)
+			current = leg{airport, nil, []*placeNode{route[i]}, 0.0, 0.0}
+		}
+	}
+
+	return
+}
+
+// printBlockTimes prints each leg's time and the total block time and, under
+// opening hours, how long the aircraft waits on the ground before each leg.
+func printBlockTimes(out io.Writer, legs []leg, plan *flightPlan) {
+	profile := plan.profile
+	total, waiting := 0.0, 0.0
+	for i, l := range legs {
+		hours := profile.legHours(l.airKm)
+		if i > 0 {
+			total += profile.turnaroundMins / 60.0
+		}
+		wait := 0.0
+		if plan.openingHours {
+			now := plan.departHours + total + waiting
+			if i == 0 {
+				wait = l.from.waitHours(now)
+			}
+			wait += l.to.waitHours(now + wait + hours)
+		}
+		total += hours
+		waiting += wait
+		if wait > 0 {
+			fmt.Fprintf(out, "    %s -> %s: %0.3f km, %s after waiting %s\n", l.from, l.to, l.distanceKm, formatHours(hours), formatHours(wait))
+		} else {
+			fmt.Fprintf(out, "    %s -> %s: %0.3f km, %s\n", l.from, l.to, l.distanceKm, formatHours(hours))
+		}
+	}
+	fmt.Fprintf(out, "    block time: %s\n", formatHours(total))
+	if waiting > 0 {
+		fmt.Fprintf(out, "    waiting: %s\n", formatHours(waiting))
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Command to report on network connectivity: report AIRCRAFT
+const REPORT_COMMAND = "report"
+
+func nodeNames(nodes []*placeNode) string {
+	names := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		names = append(names, n.Record.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// report prints which airports the given aircraft, a plane range or
+// profile name as on a flight line, can reach from one another, which
+// airports and hops the network cannot lose without splitting, and which
+// airports it cannot use at all.
+func (c *caseContext) report(args []string) {
+	if len(args) != 1 {
+		panic(REPORT_COMMAND + " needs an aircraft")
+	}
+	profile, planeRange := c.aircraftFor(args[0])
+
+	isStop := refuelStop(profile, false)
+	hopGraph := c.graph.HopGraph(isStop, usableVertex(profile, c.diversionFor(profile), false), profile.airKm, planeRange)
+
+	components := hopGraph.Components()
+	fmt.Fprintf(c.out, "%d components\n", len(components))
+	for _, component := range components {
+		fmt.Fprintf(c.out, "    %s\n", nodeNames(component))
+	}
+
+	bridges, articulations := hopGraph.CutPoints()
+	fmt.Fprintln(c.out, "articulation airports:")
+	for _, n := range articulations {
+		fmt.Fprintf(c.out, "    %s\n", n.Record)
+	}
+	fmt.Fprintln(c.out, "bridges:")
+	for _, b := range bridges {
+		fmt.Fprintf(c.out, "    %s - %s\n", b.A.Record, b.B.Record)
+	}
+
+	unusable := make([]*placeNode, 0)
+	for _, n := range c.airports() {
+		if !isStop(n) {
+			unusable = append(unusable, n)
+		}
+	}
+	if len(unusable) > 0 {
+		fmt.Fprintf(c.out, "unusable: %s\n", nodeNames(unusable))
+	}
+}
@@ -1,42 +1,75 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	gsm "google_static_map"
 	g "graph"
-	ipolate "interpolate"
+	"io"
+	"log/slog"
 	"math"
 	"os"
+	"runtime"
 	"sphere"
+	"wind"
 )
 
 const (
 	EARTH_RADIUS_KM         = 6370.0
 	INTERPOLATION_PRECISION = 0.00000001
 	DEFAULT_INPUT_FILE      = "sample.in"
-
-	// DEBUG Flags
-	READ_AIRPORTS    = 1
-	CONNECT_AIRPORTS = 2
-	PRINT_ROUTE      = 4
-	DEBUG            = 0 | PRINT_ROUTE // | CONNECT_AIRPORTS // | READ_AIRPORTS
 )
 
 var inputFileName *string = flag.String("f", DEFAULT_INPUT_FILE, "name of input file")
 var verbose *bool = flag.Bool("v", false, "verbose output")
 var readNames *bool = flag.Bool("r", false, "read airport names")
 var googleMapsURL *bool = flag.Bool("gm", false, "generate Google Maps URL")
+var printRoute *bool = flag.Bool("route", true, "print every node of each route")
+var workers *int = flag.Int("j", runtime.NumCPU(), "number of workers building each case and flying its flights")
+var windFileName *string = flag.String("wind", "", "name of gridded wind file (lat,lon,u,v in km/h)")
+var surfaceWindFileName *string = flag.String("surfacewind", "", "name of gridded surface wind file for runway crosswinds (lat,lon,u,v in km/h)")
+
+// winds is nil unless a wind file was given, in which case vertex costs
+// are still-air distances at cruise speed rather than ground distances.
+var winds *wind.Grid
+
+// windSpeeds are the cruise speeds other than -cruise of the profiles flying
+// through wind, whose still-air distances are vertex attributes from
+// ATTR_COUNT on; see aircraftProfile.airKm.
+var windSpeeds []float64
+
+// surfaceWinds is nil unless a surface wind file was given, in which case
+// profiles' crosswind limits rule out runways.
+var surfaceWinds *wind.Grid
 
 type locatable interface {
 	Location() sphere.NVector
 }
 
+// place is what each node of a case's graph records: an airport or a point
+// where two airports' circles meet.
+type place interface {
+	locatable
+	String() string
+	airport() (a *Airport, isAirport bool)
+}
+
+type (
+	placeGraph  = g.Graph[place, flightState]
+	placeNode   = g.Node[place]
+	placeVertex = g.Vertex[place]
+)
+
 // NamedLocation
 
 type Airport struct {
 	sphere.NVector
-	name string
+	name      string
+	hasFuel   bool
+	fuelPrice float64 // per kg
+	availability
+	runways []runway // nil when unknown
 }
 
 func (a *Airport) String() string {
@@ -47,6 +80,10 @@ func (a *Airport) Location() sphere.NVector {
 	return a.NVector
 }
 
+func (a *Airport) airport() (*Airport, bool) {
+	return a, true
+}
+
 type AirportIntersection struct {
 	sphere.NVector
 	airports [2]*Airport
@@ -60,277 +97,430 @@ func (a *AirportIntersection) Location() sphere.NVector {
 	return a.NVector
 }
 
+func (a *AirportIntersection) airport() (*Airport, bool) {
+	return nil, false
+}
+
+// flightPlan holds what stays fixed for the duration of a flight query.
+
+type flightPlan struct {
+	fullRange     float64
+	profile       *aircraftProfile
+	minimizeTime  bool
+	departHours   float64                   // UTC
+	openingHours  bool                      // arrivals wait for airports to open; see availability.go
+	throughClosed bool                      // lands at closed airports, to explain failures
+	diversion     *diversionChecker         // nil when the profile has no diversion limit
+	bothEnds      bool                      // searched from both ends; see bidirectional.go
+	observer      g.TraverseObserver[place] // nil unless searches are logged; see stats.go
+}
+
+// rangeIsOnlyLimit reports whether nothing about a leg depends on how the
+// aircraft got to its start, as fuel carried over from earlier legs and
+// opening hours at the time of arrival do. Only then can a flight be
+// searched backward from its destination, or pieced together from legs
+// worked out beforehand.
+func (plan *flightPlan) rangeIsOnlyLimit() bool {
+	return !plan.tracksTime() && !plan.profile.hasFuelModel()
+}
+
+// tracksTime reports whether flight states keep the time since departure,
+// which both minimizing time and opening hours need.
+func (plan *flightPlan) tracksTime() bool {
+	return plan.minimizeTime || plan.openingHours
+}
+
 // flightState
 
 type flightState struct {
+	plan           *flightPlan
 	remainingRange float64
-	fullRange      float64
+	fuelKg         float64
+	fuelPrice      float64 // per kg of the fuel last taken on; see fuelCost
+	elapsedHours   float64 // only tracked when the plan says so
+	atOrigin       bool
+	mirrored       bool // searching backward from the destination
+}
+
+func newFlightState(plan *flightPlan) flightState {
+	return flightState{plan, plan.fullRange, plan.profile.tankKg, 0.0, 0.0, true, false}
 }
 
-func newFlightState(remainingRange, fullRange float64) flightState {
-	return flightState{remainingRange, fullRange}
+func (fs flightState) VertexCost(v *placeVertex) float64 {
+	if !fs.plan.minimizeTime {
+		return fs.plan.profile.airKm(v)
+	}
+	return fs.vertexHours(v)
+}
+
+// vertexHours returns the time a vertex adds to a flight: its block time
+// and, under opening hours, any wait on the ground for the origin to open
+// or for the airport it lands at to be open on landing.
+func (fs flightState) vertexHours(v *placeVertex) float64 {
+	hours := fs.plan.profile.vertexHours(v, fs.atOrigin)
+	if !fs.plan.openingHours {
+		return hours
+	}
+	now := fs.plan.departHours + fs.elapsedHours
+	if origin, departing := v.From.Record.airport(); departing && fs.atOrigin {
+		wait := origin.waitHours(now)
+		now, hours = now+wait, hours+wait
+	}
+	if airport, landing := v.To.Record.airport(); landing {
+		hours += airport.waitHours(now + hours)
+	}
+	return hours
 }
 
-func (fs flightState) TraverseStateHelper(v *g.Vertex) (newState g.PrivateTraverseState, ok bool) {
+// Dominates keeps costlier arrivals alive only when they bring more fuel or
+// range, or fuel bought for less, since a cheaper arrival with less left
+// may not get any further or may pay more for the fuel it burns next,
+// or, under opening hours, arrive sooner, since a later arrival may have to
+// wait longer. Without the fuel model the first arrival at a node wins,
+// which is much cheaper to search, unless searching from both ends, where
+// the arrival with the most range left may be the only one that meets the
+// other search.
+func (fs flightState) Dominates(otherFs flightState) bool {
+	if fs.plan.openingHours && fs.elapsedHours > otherFs.elapsedHours {
+		return false
+	}
+	if !fs.plan.profile.hasFuelModel() {
+		return !fs.plan.bothEnds || fs.remainingRange >= otherFs.remainingRange
+	}
+	return fs.remainingRange >= otherFs.remainingRange &&
+		fs.fuelKg >= otherFs.fuelKg &&
+		fs.fuelPrice <= otherFs.fuelPrice &&
+		(fs.atOrigin || !otherFs.atOrigin)
+}
+
+func (fs flightState) TraverseStateHelper(v *placeVertex) (newState flightState, ok bool) {
+	if fs.mirrored {
+		return fs.mirroredStateHelper(v)
+	}
+
 	var newFs flightState
 
-	newFs.fullRange = fs.fullRange
+	newFs.plan = fs.plan
+	profile := fs.plan.profile
 
-	if v.Cost > fs.remainingRange {
+	airKm := profile.airKm(v)
+	if airKm > fs.remainingRange {
 		return fs, false
 	}
 
-	if _, isAirport := v.To.Record.(*Airport); isAirport {
-		newFs.remainingRange = fs.fullRange
-	} else if _, isIntersection := v.To.Record.(*AirportIntersection); isIntersection {
-		newFs.remainingRange = fs.remainingRange - v.Cost
+	if profile.hasFuelModel() {
+		newFs.fuelKg = fs.fuelKg - profile.fuelKg(profile.vertexAirborneHours(v))
+		if newFs.fuelKg < profile.reserveFuelKg() {
+			return fs, false
+		}
+		newFs.fuelPrice = fs.priceOnBoard(v)
+	}
+
+	if airport, isAirport := v.To.Record.airport(); isAirport && ((airport.closed && !fs.plan.throughClosed) || !profile.canLandAt(airport)) {
+		return fs, false
+	}
+
+	if fs.plan.diversion != nil && !fs.plan.diversion.allows(v) {
+		return fs, false
+	}
+
+	if fs.plan.tracksTime() {
+		newFs.elapsedHours = fs.elapsedHours + fs.vertexHours(v)
+	}
+
+	if airport, isAirport := v.To.Record.airport(); isAirport {
+		if !profile.hasFuelModel() {
+			newFs.remainingRange = fs.plan.fullRange
+		} else if airport.hasFuel {
+			newFs.remainingRange = fs.plan.fullRange
+			newFs.fuelKg = profile.tankKg
+			newFs.fuelPrice = airport.fuelPrice
+		} else {
+			newFs.remainingRange = fs.remainingRange - airKm
+		}
 	} else {
-		panic("unknown point in graph traversal")
+		newFs.remainingRange = fs.remainingRange - airKm
 	}
 
 	return newFs, true
 }
 
-func createRoutes(graph *g.Graph, airportNode, intersectionNode *g.Node, midpointNodes *[]*g.Node, maxRadiusKm float64) {
-	graph.ConnectBi(airportNode, intersectionNode, maxRadiusKm)
-	intersection := intersectionNode.Record.(*AirportIntersection)
-	intersectionNVec := &intersection.NVector
+// link is a connection to make between two nodes, measured apart from the
+// graph so that workers can share the measuring.
+type link struct {
+	n1, n2   *placeNode
+	distance float64    // over the ground, or 0 to measure it between the nodes
+	blocker  *zone      // the zone keeping the nodes apart, if any
+	airKm    [2]float64 // each way, when flying through wind
+	flyable  [2]bool
+	waterKm  float64      // over no land area, when land is known
+	speedKm  [][2]float64 // airKm at each of windSpeeds, infinite if unflyable
+}
 
-	for _, dest := range *midpointNodes {
-		otherIntersection := dest.Record.(*AirportIntersection)
-		distance := intersectionNVec.AngleBetween(&otherIntersection.NVector) * EARTH_RADIUS_KM
-		graph.ConnectBi(dest, intersectionNode, distance)
+// measure works out what joining a link's nodes takes, without touching the
+// graph.
+func (l *link) measure() {
+	v1 := l.n1.Record.Location()
+	v2 := l.n2.Record.Location()
+	if l.distance == 0.0 {
+		l.distance = v1.AngleBetween(&v2) * EARTH_RADIUS_KM
+	}
+	if l.blocker = blockingZone(l.n1, l.n2); l.blocker != nil {
+		return
+	}
+	if land != nil {
+		l.waterKm = overWaterKm(&v1, &v2)
+	}
+	if winds != nil {
+		l.airKm[0], l.flyable[0] = winds.AirDistance(&v1, &v2, EARTH_RADIUS_KM, *cruiseSpeed)
+		l.airKm[1], l.flyable[1] = winds.AirDistance(&v2, &v1, EARTH_RADIUS_KM, *cruiseSpeed)
+		l.speedKm = make([][2]float64, len(windSpeeds))
+		for i, speed := range windSpeeds {
+			for d, ends := range [2][2]*sphere.NVector{{&v1, &v2}, {&v2, &v1}} {
+				airKm, flyable := winds.AirDistance(ends[0], ends[1], EARTH_RADIUS_KM, speed)
+				if !flyable {
+					airKm = math.Inf(1)
+				}
+				l.speedKm[i][d] = airKm
+			}
+		}
+	}
+}
+
+// apply joins a measured link's nodes, giving each direction its own cost
+// when flying through wind and the vertices attributes when winds or land
+// are known. Nodes separated by restricted airspace are left unconnected,
+// as is a direction through wind that no cruise speed can fly; one that only
+// -cruise cannot fly costs infinity.
+func (l *link) apply(nw *network) {
+	graph := nw.graph
+	if l.blocker != nil {
+		nw.noteBlock(l.n1, l.n2, l.blocker)
+		if *verbose {
+			slog.Info("blocked", "zone", l.blocker.String(), "from", l.n1.Record.String(), "to", l.n2.Record.String())
+		}
+		return
+	}
+
+	if winds == nil && land == nil {
+		graph.ConnectBi(l.n1, l.n2, l.distance)
+		return
+	}
 
+	attributes := []float64{ATTR_GROUND_KM: l.distance, ATTR_WATER_KM: l.waterKm}
+	if winds == nil {
+		graph.ConnectBiWith(l.n1, l.n2, l.distance, attributes)
+		return
+	}
+	ends := [2][2]*placeNode{{l.n1, l.n2}, {l.n2, l.n1}}
+	for d := range ends {
+		flyable := l.flyable[d]
+		withSpeeds := attributes
+		for _, airKm := range l.speedKm {
+			withSpeeds = append(withSpeeds, airKm[d])
+			flyable = flyable || !math.IsInf(airKm[d], 1)
+		}
+		if !flyable {
+			continue
+		}
+		cost := l.airKm[d]
+		if !l.flyable[d] {
+			cost = math.Inf(1)
+		}
+		graph.ConnectUniWith(ends[d][0], ends[d][1], cost, withSpeeds)
+	}
+}
+
+// routeLinks returns the links from an airport to an intersection on its
+// circle and from the intersections already on the circle to the new one.
+func routeLinks(airportNode, intersectionNode *placeNode, midpointNodes []*placeNode, maxRadiusKm float64) []link {
+	links := []link{{n1: airportNode, n2: intersectionNode, distance: maxRadiusKm}}
+	for _, dest := range midpointNodes {
+		links = append(links, link{n1: dest, n2: intersectionNode})
 		if *verbose {
-			fmt.Printf("connecting \"%s\" and \"%s\"\n", intersection, otherIntersection)
+			slog.Info("connecting", "from", intersectionNode.Record.String(), "to", dest.Record.String())
+		}
+	}
+	return links
+}
+
+// readAirport reads an airport's longitude, latitude and, if names are
+// read, its names; otherwise it is named after its index.
+func readAirport(in *bufio.Reader, index int) (location *sphere.NVector, names []string) {
+	var lat, lon float64
+	_, err := fmt.Fscan(in, &lon, &lat)
+	if nil != err {
+		panic("couldn't read lon-lat")
+	}
+
+	var name string
+	names = make([]string, 0)
+	if *readNames {
+		for _, err = fmt.Fscanf(in, "%q", &name); err == nil; _, err = fmt.Fscanf(in, "%q", &name) {
+			names = append(names, name)
 		}
+	} else {
+		name = fmt.Sprintf("Airport %d", index)
+		names = append(names, name)
 	}
+
+	return sphere.NewNVectorFromLatLongDeg(lat, lon), names
 }
 
 func main() {
-	flag.Parse() // Scan the arguments list 
+	flag.Parse() // Scan the arguments list
+	if *debug {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	}
 
-	in, err := os.Open(*inputFileName)
+	command := flag.Arg(0)
+	switch command {
+	case "", TOUR_COMMAND, REPORT_COMMAND, EXPORT_COMMAND, INTERACTIVE_COMMAND:
+	default:
+		panic("unknown command \"" + command + "\"")
+	}
+
+	config := loadSettings()
+
+	inFile, err := os.Open(*inputFileName)
 	if err != nil {
 		panic("couldn't open input file \"" + *inputFileName + "\"")
 	}
-	defer func() { in.Close() }()
-
-	for caseNumber := 1; ; caseNumber++ {
-		var airportCount int
-		var maxRadiusKm float64
+	defer func() { inFile.Close() }()
 
-		_, err = fmt.Fscan(in, &airportCount, &maxRadiusKm)
-		if nil != err {
-			break
-		}
+	args := flag.Args()
+	if len(args) > 0 {
+		args = args[1:]
+	}
+	run(config, bufio.NewReader(inFile), os.Stdout, command, args)
+}
 
-		fmt.Printf("Case %d:\n", caseNumber)
+// readWinds reads a gridded wind file.
+func readWinds(fileName string) *wind.Grid {
+	windFile, err := os.Open(fileName)
+	if err != nil {
+		panic("couldn't open wind file \"" + fileName + "\"")
+	}
+	defer func() { windFile.Close() }()
 
-		airportsByIndex := make([]*g.Node, airportCount+1)
-		airportsByName := make(map[string]*g.Node)
-		airportRadiusNodes := make(map[*g.Node]*[]*g.Node)
-		graph := g.NewGraph()
+	grid, err := wind.ReadGrid(windFile)
+	if err != nil {
+		panic(fmt.Sprintf("couldn't read wind file -- %s", err))
+	}
+	return grid
+}
 
-		for i := 1; i <= airportCount; i++ {
-			var lat, lon float64
-			_, err = fmt.Fscan(in, &lon, &lat)
-			if nil != err {
-				panic("couldn't read lon-lat")
-			}
+// loadSettings interprets the command line flags and reads the side files
+// they name.
+func loadSettings() *settings {
+	minimizeTime := false
+	switch *optimize {
+	case OPTIMIZE_DISTANCE:
+	case OPTIMIZE_TIME:
+		minimizeTime = true
+	default:
+		panic("unknown optimization \"" + *optimize + "\"")
+	}
+	profile := newAircraftProfileFromFlags()
+	departHours := departureHours()
 
-			var name string
-			names := make([]string, 0)
-			if *readNames {
-				for _, err = fmt.Fscanf(in, "%q", &name); err == nil; _, err = fmt.Fscanf(in, "%q", &name) {
-					names = append(names, name)
-				}
-			} else {
-				name = fmt.Sprintf("Airport %d", i)
-				names = append(names, name)
-			}
+	if *windFileName != "" {
+		winds = readWinds(*windFileName)
+	}
 
-			ap := sphere.NewNVectorFromLatLongDeg(lat, lon)
+	if *surfaceWindFileName != "" {
+		surfaceWinds = readWinds(*surfaceWindFileName)
+	}
 
-			airport := Airport{*ap, names[0]}
+	if *zoneFileName != "" {
+		zones = readZones(*zoneFileName)
+	}
 
-			node := graph.NewNode(&airport)
+	if *landFileName != "" {
+		land = readZones(*landFileName)
+	}
 
-			airportsByIndex[i] = node
-			for _, name = range names {
-				airportsByName[name] = node
-			}
-			sl := make([]*g.Node, 0)
-			airportRadiusNodes[node] = &sl
-		}
+	airportAvailability := make(map[string]availability)
+	if *availabilityFileName != "" {
+		airportAvailability = readAvailability(*availabilityFileName)
+	}
 
-		radiusAngleRadians := maxRadiusKm / EARTH_RADIUS_KM
-		circleRadiusKm := math.Sin(radiusAngleRadians) * EARTH_RADIUS_KM
-		circleEarthRadiusKm := math.Cos(radiusAngleRadians) * EARTH_RADIUS_KM
+	airportRunways := make(map[string][]runway)
+	if *attributesFileName != "" {
+		airportRunways = readAttributes(*attributesFileName)
+	}
 
-		if *verbose {
-			fmt.Printf("circle radius = %f; earth circle radius = %f\n", circleRadiusKm, circleEarthRadiusKm)
-		}
+	profiles := make(map[string]*aircraftProfile)
+	if *profileFileName != "" {
+		profiles = readProfiles(*profileFileName, profile)
+	}
+	if winds != nil {
+		windSpeeds = assignWindColumns(profiles)
+	}
 
-		for airport1Node, midpoints1 := range airportRadiusNodes {
-			airport1 := airport1Node.Record.(*Airport)
-			if DEBUG&CONNECT_AIRPORTS != 0 {
-				fmt.Printf("Connecting %q to other airports.\n", airport1.name)
-			}
-		inner:
-			for airport2Node, midpoints2 := range airportRadiusNodes {
-				airport2 := airport2Node.Record.(*Airport)
+	fuelPrices := make(map[string]float64)
+	if *fuelFileName != "" {
+		fuelPrices = readFuelPrices(*fuelFileName)
+	}
 
-				if !airport1.NVector.LessThan(&airport2.NVector) {
-					continue inner
-				}
+	// waiting for an airport to open takes time but adds no distance
+	openingHours := hasOpeningHours(airportAvailability)
+	if openingHours && !minimizeTime {
+		slog.Warn("opening hours only delay flights, so they make no difference without -opt " + OPTIMIZE_TIME)
+		openingHours = false
+	}
 
-				airportAngle := airport1.NVector.AngleBetween(&airport2.NVector)
-				distance := airportAngle * EARTH_RADIUS_KM
-				if *verbose {
-					fmt.Printf("airports %s and %s are %f km apart consider:%t.\n", airport1.name, airport2.name, distance, distance <= 2*maxRadiusKm)
-				}
-				if distance <= 2*maxRadiusKm {
-					graph.ConnectBi(airport1Node, airport2Node, distance)
-
-					perp := airport1.NVector.CrossProduct(&airport2.NVector).Normalize()
-					toMeetV1 := perp.CrossProduct(&airport1.NVector)
-					toMeetV2 := airport2.NVector.CrossProduct(perp)
-
-					discCenter1 := airport1.ScaleTo(circleEarthRadiusKm)
-					discCenter2 := airport2.ScaleTo(circleEarthRadiusKm)
-					discMeetDistance := math.Tan(airportAngle/2) * circleEarthRadiusKm
-
-					discMeetPoint := discCenter1.Add(toMeetV1.ScaleTo(discMeetDistance))
-					discMeetPointAlt := discCenter2.Add(toMeetV2.ScaleTo(discMeetDistance)) // REMOVE
-
-					if *verbose {
-						fmt.Printf("%s and %s -> %s\n", airport1.name, airport2.name, toMeetV1.String())
-						fmt.Printf("    %s==%s\n", discMeetPoint.String(), discMeetPointAlt.String())
-					}
-
-					sphereRadiusFunc := func(in float64) float64 {
-						return perp.ScaleBy(in).Add(discMeetPoint).Magnitude()
-					}
-
-					intersectionFactor1, ok1 := ipolate.Interpolator(0.0, circleRadiusKm, EARTH_RADIUS_KM, INTERPOLATION_PRECISION, sphereRadiusFunc)
-					intersectionFactor2, ok2 := ipolate.Interpolator(0.0, -circleRadiusKm, EARTH_RADIUS_KM, INTERPOLATION_PRECISION, sphereRadiusFunc)
-
-					if ok1 && ok2 {
-						airportPair := [2]*Airport{airport1Node.Record.(*Airport), airport2Node.Record.(*Airport)}
-
-						intersection1 := perp.ScaleBy(intersectionFactor1).Add(discMeetPoint)
-						// REMOVE name1 := fmt.Sprintf("intersection A of %s and %s", airport1.name, airport2.name)
-						location1 := AirportIntersection{*intersection1, airportPair}
-						node1 := graph.NewNode(&location1)
-
-						intersection2 := perp.ScaleBy(intersectionFactor2).Add(discMeetPoint)
-						// REMOVE name2 := fmt.Sprintf("intersection B of %s and %s", airport1.name, airport2.name)
-						location2 := AirportIntersection{*intersection2, airportPair}
-						node2 := graph.NewNode(&location2)
-
-						createRoutes(graph, airport1Node, node1, midpoints1, maxRadiusKm)
-						createRoutes(graph, airport1Node, node2, midpoints1, maxRadiusKm)
-
-						createRoutes(graph, airport2Node, node1, midpoints2, maxRadiusKm)
-						createRoutes(graph, airport2Node, node2, midpoints2, maxRadiusKm)
-
-						*midpoints1 = append(*midpoints1, node1, node2)
-						*midpoints2 = append(*midpoints2, node1, node2)
-					} else if ok1 || ok2 {
-						panic("only one point found with two nearby airports")
-					} else {
-						panic("no points found with two nearby airports")
-					}
-				} else {
-					if *verbose {
-						fmt.Println("too great")
-					}
-				}
-			}
-		}
+	return &settings{profile, profiles, minimizeTime, departHours, openingHours, hasClosures(airportAvailability),
+		&airportData{airportRunways, airportAvailability, fuelPrices},
+		parseCriteria(*criteriaSpec, 0), parseCriteria(*paretoSpec, 2)}
+}
 
-		var flightCount int
-		fmt.Fscan(in, &flightCount)
+// run answers every case read from in, writing the results to out. The
+// command and its arguments say what to do with each case; see main.
+func run(config *settings, in *bufio.Reader, out io.Writer, command string, args []string) {
+	for caseNumber := 1; ; caseNumber++ {
+		var airportCount int
+		var maxRadiusKm float64
 
-		for flight := 0; flight < flightCount; flight++ {
-			var planeRange float64
-			var airportFrom, airportTo *g.Node
+		_, err := fmt.Fscan(in, &airportCount, &maxRadiusKm)
+		if nil != err {
+			break
+		}
 
-			if *readNames {
-				var airportFromName, airportToName string
-				_, err = fmt.Fscanf(in, "%q %q %f", &airportFromName, &airportToName, &planeRange)
-				if err != nil {
-					panic("could not read names of airports on route")
-				}
-				airportFrom = airportsByName[airportFromName]
-				airportTo = airportsByName[airportToName]
-			} else {
-				var airportFromIndex, airportToIndex int
-				_, err = fmt.Fscanf(in, "%d %d %f", &airportFromIndex, &airportToIndex, &planeRange)
-				if err != nil {
-					panic("could not read indexes of airports on route")
-				} else {
-					airportFrom = airportsByIndex[airportFromIndex]
-					airportTo = airportsByIndex[airportToIndex]
-				}
-			}
+		fmt.Fprintf(out, "Case %d:\n", caseNumber)
 
-			if *verbose {
-				fmt.Printf("from %s to %s with max plane range of %f\n", airportFrom.Record.(*Airport).String(), airportTo.Record.(*Airport).String(), planeRange)
-			}
+		nw := newNetwork(maxRadiusKm)
 
-			fs := newFlightState(planeRange, planeRange)
+		airports := make([]*Airport, airportCount)
+		airportNames := make([][]string, airportCount)
+		for i := range airports {
+			location, names := readAirport(in, i+1)
+			airports[i], airportNames[i] = config.newAirport(location, names), names
+		}
+		nw.addAirports(airports, airportNames, *workers)
 
-			route, distance, ok := graph.Traverse(fs, airportFrom, airportTo)
+		c := &caseContext{config, nw, out, make(map[string][]groupMember)}
 
-			if ok {
-				fmt.Printf("%0.3f\n", distance)
-				if DEBUG&PRINT_ROUTE != 0 {
-					for _, n := range route {
-						fmt.Println(n.Record.String())
-					}
-				}
-				if *googleMapsURL {
-					gmap := gsm.NewMap(640, 640, 2)
-					airportsSeen := make(map[*Airport]bool)
-					flightPath := make([]sphere.NVector, 0, len(route))
-					for _, n := range route {
-						if airport, isAirport := n.Record.(*Airport); isAirport {
-							airportsSeen[airport] = true
-							lat, lon := airport.NVector.ToLatLonDegrees()
-							gmap.AddMarker(gsm.NewPoint(lat, lon))
-							flightPath = append(flightPath, airport.NVector)
-						} else if intersection, isIntersection := n.Record.(*AirportIntersection); isIntersection {
-							airportsSeen[intersection.airports[0]] = true
-							airportsSeen[intersection.airports[1]] = true
-							// lat, lon := intersection.NVector.ToLatLonDegrees()
-							// gmap.AddMarker(gsm.NewPoint(lat, lon))
-							flightPath = append(flightPath, intersection.NVector)
-						}
-					}
-					for airport, _ := range airportsSeen {
-						pathPoints := airport.NVector.CircleOnSphere(EARTH_RADIUS_KM, maxRadiusKm, 33)
-						polyLine := gsm.NewPolyLine()
-						polyLine.ClosePath = true
-						polyLine.SetWeight(1)
-						polyLine.SetColor("0x0000ffff")
-						polyLine.SetFillColor("0x8080ff40")
-						for _, pp := range pathPoints {
-							lat, lon := pp.ToLatLonDegrees()
-							polyLine.AddPointLatLon(lat, lon)
-						}
-						gmap.AddPath(polyLine)
-					}
-					flightPathPolyLine := makePolyLine(flightPath)
-					flightPathPolyLine.SetWeight(1)
-					flightPathPolyLine.SetColor("0xff0000ff")
-					gmap.AddPath(flightPathPolyLine)
-					fmt.Println(gmap.Encode(true))
-				}
-			} else {
-				fmt.Println("impossible")
+		var flightCount int
+		fmt.Fscan(in, &flightCount)
+		lines := c.readFlightLines(in, flightCount)
+
+		switch command {
+		case "":
+			c.runFlightLines(lines, *workers)
+		case TOUR_COMMAND:
+			c.planTour(args)
+		case REPORT_COMMAND:
+			c.report(args)
+		case EXPORT_COMMAND:
+			exportArgs := append([]string(nil), args...)
+			if len(exportArgs) > 1 {
+				exportArgs[1] = caseFileName(exportArgs[1], caseNumber)
 			}
+			c.export(exportArgs)
+		case INTERACTIVE_COMMAND:
+			c.interact(bufio.NewReader(os.Stdin))
+			return
 		}
 	}
 }
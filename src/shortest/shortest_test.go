@@ -0,0 +1,1589 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	g "graph"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sphere"
+	"strings"
+	"testing"
+	"tour"
+	"wind"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected outputs in testdata")
+
+// Each case file is run with the default settings and its output compared
+// with the expected output checked in under testdata. Flying over
+// contracted overlays must give the same output.
+var regressionCases = []struct {
+	input, expected string
+	names           bool
+	long            bool
+	contracted      bool
+}{
+	{"../../sample.in", "sample.out", false, false, false},
+	{"../../sample_w_names.in", "sample.out", true, false, false},
+	{"../../usairports.in", "usairports.out", true, true, false},
+	{"../../sample.in", "sample.out", false, false, true},
+}
+
+func TestRegression(t *testing.T) {
+	defer func(savedNames, savedOverlay, savedContract bool) {
+		*readNames, *overlay, *contract = savedNames, savedOverlay, savedContract
+	}(*readNames, *overlay, *contract)
+
+	for _, rc := range regressionCases {
+		if rc.long && testing.Short() {
+			continue
+		}
+
+		inFile, err := os.Open(rc.input)
+		if err != nil {
+			t.Fatalf("couldn't open %s -- %s", rc.input, err)
+		}
+		*readNames = rc.names
+		*overlay, *contract = rc.contracted, rc.contracted
+		var out bytes.Buffer
+		run(loadSettings(), bufio.NewReader(inFile), &out, "", nil)
+		inFile.Close()
+
+		expectedFile := filepath.Join("testdata", rc.expected)
+		if *update && !rc.contracted {
+			if err = ioutil.WriteFile(expectedFile, out.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		expected, err := ioutil.ReadFile(expectedFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Bytes(), expected) {
+			t.Errorf("output of %s (contracted overlays %t) differs from %s", rc.input, rc.contracted, expectedFile)
+		}
+	}
+}
+
+// readCase reads the airports of the first case in a file.
+func readCase(b *testing.B, fileName string) (maxRadiusKm float64, airports []*Airport, names [][]string) {
+	inFile, err := os.Open(fileName)
+	if err != nil {
+		b.Fatalf("couldn't open %s -- %s", fileName, err)
+	}
+	defer inFile.Close()
+	in := bufio.NewReader(inFile)
+
+	var airportCount int
+	if _, err = fmt.Fscan(in, &airportCount, &maxRadiusKm); err != nil {
+		b.Fatal(err)
+	}
+	config := loadSettings()
+	airports = make([]*Airport, airportCount)
+	names = make([][]string, airportCount)
+	for i := range airports {
+		location, airportNames := readAirport(in, i+1)
+		airports[i], names[i] = config.newAirport(location, airportNames), airportNames
+	}
+	return
+}
+
+// benchmarkBuild builds the network of US airports, optionally flying
+// through a made-up wind, which puts most of the work in measuring links.
+func benchmarkBuild(b *testing.B, workers int, windy bool) {
+	defer func(savedNames bool, savedWinds *wind.Grid) { *readNames, winds = savedNames, savedWinds }(*readNames, winds)
+	*readNames = true
+	maxRadiusKm, airports, names := readCase(b, "../../usairports.in")
+	if windy {
+		var grid strings.Builder
+		for lat := 15; lat <= 75; lat += 5 {
+			for lon := -180; lon <= -60; lon += 5 {
+				fmt.Fprintf(&grid, "%d,%d,%d,%d\n", lat, lon, 40+lat, lon/10)
+			}
+		}
+		var err error
+		if winds, err = wind.ReadGrid(strings.NewReader(grid.String())); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		newNetwork(maxRadiusKm).addAirports(airports, names, workers)
+	}
+}
+
+func BenchmarkBuildSerial(b *testing.B) {
+	benchmarkBuild(b, 1, false)
+}
+
+func BenchmarkBuildParallel(b *testing.B) {
+	benchmarkBuild(b, runtime.NumCPU(), false)
+}
+
+func BenchmarkBuildWindSerial(b *testing.B) {
+	benchmarkBuild(b, 1, true)
+}
+
+func BenchmarkBuildWindParallel(b *testing.B) {
+	benchmarkBuild(b, runtime.NumCPU(), true)
+}
+
+// BenchmarkLongRoutes flies coast to coast with a short range, which makes
+// for long paths with many stops.
+func BenchmarkLongRoutes(b *testing.B) {
+	defer func(saved bool) { *readNames = saved }(*readNames)
+	*readNames = true
+	maxRadiusKm, airports, names := readCase(b, "../../usairports.in")
+	nw := newNetwork(maxRadiusKm)
+	nw.addAirports(airports, names, 1)
+	c := &caseContext{loadSettings(), nw, ioutil.Discard, nil}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, flight := range [][2]string{{"LAX", "LGA"}, {"SEA", "EYW"}} {
+			if _, _, _, failure := c.fly(c.lookup(flight[0]), c.lookup(flight[1]), c.profile, 250); failure != "" {
+				b.Fatalf("%s to %s is %s", flight[0], flight[1], failure)
+			}
+		}
+	}
+}
+
+// TestExport exports each case of the sample as GraphML and checks that a
+// well-formed file is written for each.
+func TestExport(t *testing.T) {
+	inFile, err := os.Open("../../sample.in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inFile.Close()
+	dir := t.TempDir()
+	var out bytes.Buffer
+	run(loadSettings(), bufio.NewReader(inFile), &out, EXPORT_COMMAND, []string{EXPORT_GRAPHML, filepath.Join(dir, "network.graphml")})
+
+	for _, name := range []string{"network.graphml", "network2.graphml"} {
+		file, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%s not written -- %s", name, err)
+		}
+		nodes := 0
+		for decoder := xml.NewDecoder(file); ; {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well formed -- %s", name, err)
+			}
+			if start, ok := token.(xml.StartElement); ok && start.Name.Local == "node" {
+				nodes++
+			}
+		}
+		file.Close()
+		if nodes == 0 {
+			t.Errorf("%s has no nodes", name)
+		}
+	}
+}
+
+// TestGroups flies between groups of the sample's airports, with costs for
+// getting to some of them.
+func TestGroups(t *testing.T) {
+	input := `3 2000
+0 0
+0 30
+30 0
+3
+group WEST 1 2 +500
+WEST 3 5000
+group FAR 1 +2000 2
+FAR 3 5000
+3 FAR 5000
+`
+	expected := `Case 1:
+Airport 1 -> Airport 3
+3335.324
+Airport 1
+Airport 3
+Airport 2 -> Airport 3
+4724.686
+Airport 2
+Airport 2/Airport 1
+Airport 3/Airport 1
+Airport 3
+Airport 3 -> Airport 2
+4724.686
+Airport 3
+Airport 3/Airport 1
+Airport 2/Airport 1
+Airport 2
+`
+	var out bytes.Buffer
+	run(loadSettings(), bufio.NewReader(strings.NewReader(input)), &out, "", nil)
+	if out.String() != expected {
+		t.Errorf("flights between groups give\n%s\nrather than\n%s", out.String(), expected)
+	}
+}
+
+// interactCase builds the first case of an input and runs interactive
+// commands on it, returning what they print.
+func interactCase(input, commands string) string {
+	in := bufio.NewReader(strings.NewReader(input))
+	var airportCount int
+	var maxRadiusKm float64
+	fmt.Fscan(in, &airportCount, &maxRadiusKm)
+
+	config := loadSettings()
+	nw := newNetwork(maxRadiusKm)
+	airports, names := make([]*Airport, airportCount), make([][]string, airportCount)
+	for i := range airports {
+		location, airportNames := readAirport(in, i+1)
+		airports[i], names[i] = config.newAirport(location, airportNames), airportNames
+	}
+	nw.addAirports(airports, names, 1)
+
+	var out bytes.Buffer
+	c := &caseContext{config, nw, &out, make(map[string][]groupMember)}
+	c.interact(bufio.NewReader(strings.NewReader(commands)))
+	return out.String()
+}
+
+// TestRemoveGroupMember removes an airport of a group, whose node's id then
+// belongs to another airport, and flies to and from what is left of the
+// group.
+func TestRemoveGroupMember(t *testing.T) {
+	input := `3 2000
+0 0
+0 10
+10 0
+0
+`
+	commands := `group G 1 2
+route G 3 5000
+remove 1
+route G 3 5000
+route 3 G 5000
+remove 2
+route G 3 5000
+`
+	expected := `defined G
+Airport 1 -> Airport 3
+1111.775
+Airport 1
+Airport 3
+removed Airport 1
+Airport 2 -> Airport 3
+1568.274
+Airport 2
+Airport 3
+Airport 3 -> Airport 2
+1568.274
+Airport 3
+Airport 2
+removed Airport 2
+impossible (no airport of G is usable)
+`
+	if out := interactCase(input, commands); out != expected {
+		t.Errorf("flights after removing group members give\n%s\nrather than\n%s", out, expected)
+	}
+}
+
+// randomCase places 40 airports at random over a few hundred miles, with
+// circles of a random radius.
+func randomCase(r *rand.Rand, config *settings) *caseContext {
+	nw := newNetwork(100.0 + 100.0*r.Float64())
+	airports, names := make([]*Airport, 40), make([][]string, 40)
+	for i := range airports {
+		names[i] = []string{fmt.Sprintf("A%d", i)}
+		location := sphere.NewNVectorFromLatLongDeg(35.0+8.0*r.Float64(), -100.0+10.0*r.Float64())
+		airports[i] = config.newAirport(location, names[i])
+	}
+	nw.addAirports(airports, names, 1)
+	return &caseContext{config, nw, ioutil.Discard, nil}
+}
+
+// randomFlight picks two airports of a case and a range between one and
+// three times its circles' radius.
+func randomFlight(r *rand.Rand, c *caseContext) (from, to *placeNode, planeRange float64) {
+	airports := c.airports()
+	return airports[r.Intn(len(airports))], airports[r.Intn(len(airports))], c.maxRadiusKm * (1.0 + 2.0*r.Float64())
+}
+
+// TestBidirectional flies random flights over random sets of airports and
+// checks that searching from both ends finds routes as short as searching
+// from one end with the same states does, and no longer than the usual
+// search, which keeps only the first arrival at each node.
+func TestBidirectional(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	config := loadSettings()
+	for set := 0; set < 6; set++ {
+		c := randomCase(r, config)
+		for flight := 0; flight < 20; flight++ {
+			from, to, planeRange := randomFlight(r, c)
+			plan := &flightPlan{planeRange, config.profile, false, 0.0, false, false, nil, true, nil}
+			_, expected, expectedOk := c.graph.Traverse(newFlightState(plan), from, to)
+			route, cost, ok, _ := c.traverse(plan, from, to)
+			if ok != expectedOk || math.Abs(cost-expected) > 1e-6 {
+				t.Errorf("set %d: %s to %s with range %f costs %f (%t) from both ends but %f (%t) from one",
+					set, from, to, planeRange, cost, ok, expected, expectedOk)
+				continue
+			}
+
+			plan.bothEnds = false
+			if _, usual, usualOk, _ := c.traverse(plan, from, to); usualOk && (!ok || cost > usual+1e-6) {
+				t.Errorf("set %d: %s to %s with range %f costs %f from both ends but %f as usual",
+					set, from, to, planeRange, cost, usual)
+			}
+			if !ok {
+				continue
+			}
+			if route[0] != from || route[len(route)-1] != to {
+				t.Errorf("set %d: route %v does not run from %s to %s", set, route, from, to)
+			}
+			for _, l := range splitLegs(route, config.profile) {
+				if l.airKm > planeRange+1e-6 {
+					t.Errorf("set %d: leg from %s to %s is %f, beyond the range of %f", set, l.from, l.to, l.airKm, planeRange)
+				}
+			}
+		}
+	}
+}
+
+// TestRangeLimitedMeeting flies a flight on which the cheapest arrival at
+// some point leaves too little range to go on. The usual search, keeping
+// only that arrival, lands on the way, while searching from both ends
+// keeps a costlier arrival with more range left and gets through.
+func TestRangeLimitedMeeting(t *testing.T) {
+	defer func(saved bool) { *bidirectional = saved }(*bidirectional)
+
+	input := `4 156
+3.5 1.3
+3.7 5.7
+1.3 0.4
+3.6 3.1
+1
+2 3 422
+`
+	expected := map[bool]string{false: `Case 1:
+753.940
+Airport 2
+Airport 4
+Airport 1
+Airport 3
+`, true: `Case 1:
+690.520
+Airport 2
+Airport 4
+Airport 4/Airport 1
+Airport 1/Airport 3
+Airport 3
+`}
+	for _, *bidirectional = range []bool{false, true} {
+		var out bytes.Buffer
+		run(loadSettings(), bufio.NewReader(strings.NewReader(input)), &out, "", nil)
+		if out.String() != expected[*bidirectional] {
+			t.Errorf("with -bidi %t the flight gives\n%s\nrather than\n%s", *bidirectional, out.String(), expected[*bidirectional])
+		}
+	}
+}
+
+// TestOverlay checks that flights over each case's overlays, contracted or
+// not, are as short as those found by searching the whole network while
+// keeping every arrival with more range left, which the usual search does
+// not, so it may find longer routes.
+func TestOverlay(t *testing.T) {
+	defer func(savedOverlay, savedContract bool) { *overlay, *contract = savedOverlay, savedContract }(*overlay, *contract)
+
+	r := rand.New(rand.NewSource(2))
+	config := loadSettings()
+	for set := 0; set < 6; set++ {
+		c := randomCase(r, config)
+		for flight := 0; flight < 20; flight++ {
+			from, to, planeRange := randomFlight(r, c)
+			plan := &flightPlan{planeRange, config.profile, false, 0.0, false, false, nil, true, nil}
+			_, expected, expectedOk := c.graph.Traverse(newFlightState(plan), from, to)
+			plan.bothEnds = false
+
+			for _, contracted := range []bool{false, true} {
+				*overlay, *contract = true, contracted
+				c.overlays = make(map[overlayKey]*airportOverlay)
+				route, cost, ok, _ := c.traverse(plan, from, to)
+				if ok != expectedOk || math.Abs(cost-expected) > 1e-6 {
+					t.Errorf("set %d: %s to %s with range %f costs %f (%t) over the overlay (contracted %t) but %f (%t) otherwise",
+						set, from, to, planeRange, cost, ok, contracted, expected, expectedOk)
+					continue
+				}
+				if !ok {
+					continue
+				}
+				if route[0] != from || route[len(route)-1] != to {
+					t.Errorf("set %d: route %v does not run from %s to %s", set, route, from, to)
+				}
+				distance := 0.0
+				for i := 1; i < len(route); i++ {
+					distance += route[i-1].VertexTo(route[i]).Cost
+				}
+				if math.Abs(distance-cost) > 1e-6 {
+					t.Errorf("set %d: route %v is %f long but costs %f", set, route, distance, cost)
+				}
+				for _, l := range splitLegs(route, config.profile) {
+					if l.airKm > planeRange+1e-6 {
+						t.Errorf("set %d: leg from %s to %s is %f, beyond the range of %f", set, l.from, l.to, l.airKm, planeRange)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestConstrainingZones flies between two airports whose direct edge one
+// zone blocks, while another blocks the direct edge from the origin to an
+// airport behind it, which could not have shortened the route.
+func TestConstrainingZones(t *testing.T) {
+	defer func(saved []*zone) { zones = saved }(zones)
+	zones = []*zone{
+		{name: "ahead", center: sphere.NewNVectorFromLatLongDeg(0.0, 2.0), radiusAngle: 20.0 / EARTH_RADIUS_KM},
+		{name: "behind", center: sphere.NewNVectorFromLatLongDeg(0.0, -2.0), radiusAngle: 20.0 / EARTH_RADIUS_KM},
+	}
+
+	config := loadSettings()
+	nw := newNetwork(300.0)
+	airports, names := make([]*Airport, 3), make([][]string, 3)
+	for i, lon := range []float64{0.0, 4.0, -4.0} {
+		names[i] = []string{fmt.Sprintf("A%d", i)}
+		airports[i] = config.newAirport(sphere.NewNVectorFromLatLongDeg(0.0, lon), names[i])
+	}
+	nw.addAirports(airports, names, 1)
+	c := &caseContext{config, nw, ioutil.Discard, nil}
+
+	route, _, _, failure := c.fly(c.lookup("1"), c.lookup("2"), config.profile, 1000.0)
+	if failure != "" {
+		t.Fatalf("cannot fly round the zone (%s)", failure)
+	}
+	var avoided []string
+	for _, z := range c.constrainingZones(route) {
+		avoided = append(avoided, z.name)
+	}
+	if strings.Join(avoided, ",") != "ahead" {
+		t.Errorf("route %v avoids %v rather than only the zone ahead", route, avoided)
+	}
+}
+
+// TestIncremental adds and removes airports one at a time, with contracted
+// overlays and a diversion checker already worked out and a zone in the
+// way, and checks that the network is then as if built afresh from the
+// airports left, as are the flights over it and its overlays, which are
+// only contracted again once flown over.
+func TestIncremental(t *testing.T) {
+	defer func(saved []*zone, savedOverlay, savedContract bool) {
+		zones, *overlay, *contract = saved, savedOverlay, savedContract
+	}(zones, *overlay, *contract)
+	*contract = true
+	zones = []*zone{{name: "Z", center: sphere.NewNVectorFromLatLongDeg(39.0, -95.0), radiusAngle: 100.0 / EARTH_RADIUS_KM}}
+
+	r := rand.New(rand.NewSource(7))
+	config := loadSettings()
+	diverting := *config.profile
+	diverting.maxDiversionKm = 60.0
+	profiles := []*aircraftProfile{config.profile, &diverting}
+
+	radiusKm := 120.0
+	airports, names := make([]*Airport, 50), make([][]string, 50)
+	for i := range airports {
+		names[i] = []string{fmt.Sprintf("A%d", i)}
+		location := sphere.NewNVectorFromLatLongDeg(35.0+8.0*r.Float64(), -100.0+10.0*r.Float64())
+		airports[i] = config.newAirport(location, names[i])
+	}
+
+	nw := newNetwork(radiusKm)
+	nw.addAirports(airports[:30], names[:30], 1)
+	edited := &caseContext{config, nw, ioutil.Discard, nil}
+	plans := make([]*flightPlan, 0)
+	for _, profile := range profiles {
+		for _, planeRange := range []float64{radiusKm, 2.5 * radiusKm} {
+			plan := &flightPlan{planeRange, profile, false, 0.0, false, false, edited.diversionFor(profile), false, nil}
+			edited.overlayFor(plan)
+			plans = append(plans, plan)
+		}
+	}
+	for i := 30; i < len(airports); i++ {
+		nw.addAirport(airports[i], names[i])
+		left := nw.airports()
+		nw.removeAirport(left[r.Intn(len(left))])
+	}
+	for key, o := range nw.overlays {
+		if o.hierarchy != nil {
+			t.Errorf("overlay for range %f was contracted again before being flown over", key.planeRange)
+		}
+	}
+
+	left := nw.airports()
+	leftAirports, leftNames := make([]*Airport, len(left)), make([][]string, len(left))
+	for i, n := range left {
+		leftAirports[i] = n.Record.(*Airport)
+		leftNames[i] = []string{leftAirports[i].name}
+	}
+	fresh := &caseContext{config, newNetwork(radiusKm), ioutil.Discard, nil}
+	fresh.addAirports(leftAirports, leftNames, 1)
+
+	// the same vertices and blocks, by where they run
+	describe := func(c *caseContext) (vertices, blocks []string) {
+		for _, n := range c.graph.Nodes() {
+			from := n.Record.Location()
+			for _, other := range c.graph.Nodes() {
+				if v := n.VertexTo(other); v != nil {
+					to := other.Record.Location()
+					vertices = append(vertices, fmt.Sprintf("%s %s %.6f", from.String(), to.String(), v.Cost))
+				}
+			}
+			for _, block := range c.zoneBlocks[n] {
+				if !c.graph.Has(block.other) {
+					t.Errorf("%s is kept from %s, which was removed", n.Record, block.other.Record)
+				}
+				to := block.other.Record.Location()
+				blocks = append(blocks, fmt.Sprintf("%s %s %s", from.String(), to.String(), block.zone))
+			}
+		}
+		sort.Strings(vertices)
+		sort.Strings(blocks)
+		return
+	}
+	vertices, blocks := describe(edited)
+	freshVertices, freshBlocks := describe(fresh)
+	if strings.Join(vertices, "\n") != strings.Join(freshVertices, "\n") {
+		t.Errorf("edited network has %d vertices differing from the %d built afresh", len(vertices), len(freshVertices))
+	}
+	if len(blocks) == 0 || strings.Join(blocks, "\n") != strings.Join(freshBlocks, "\n") {
+		t.Errorf("edited network has %d zone blocks rather than the %d built afresh", len(blocks), len(freshBlocks))
+	}
+
+	// the same hops over each overlay, by the airports they join
+	hops := func(o *airportOverlay) map[string]float64 {
+		costs := make(map[string]float64)
+		for _, n := range o.Nodes() {
+			for _, other := range o.Nodes() {
+				if v := n.VertexTo(other); v != nil {
+					costs[n.Record.String()+" "+other.Record.String()] = v.Cost
+				}
+			}
+		}
+		return costs
+	}
+	for p, plan := range plans {
+		freshPlan := *plan
+		freshPlan.diversion = fresh.diversionFor(plan.profile)
+		editedHops, freshHops := hops(edited.overlayFor(plan)), hops(fresh.overlayFor(&freshPlan))
+		if len(editedHops) != len(freshHops) {
+			t.Errorf("plan %d: edited overlay has %d hops rather than the %d built afresh", p, len(editedHops), len(freshHops))
+		}
+		for hop, cost := range freshHops {
+			if math.Abs(editedHops[hop]-cost) > 1e-6 {
+				t.Errorf("plan %d: hop %s costs %f edited but %f built afresh", p, hop, editedHops[hop], cost)
+			}
+		}
+	}
+
+	for _, *overlay = range []bool{false, true} {
+		for p, plan := range plans {
+			freshPlan := *plan
+			freshPlan.diversion = fresh.diversionFor(plan.profile)
+			for flight := 0; flight < 20; flight++ {
+				from, to := r.Intn(len(left)), r.Intn(len(left))
+				_, cost, ok, _ := edited.traverse(plan, left[from], left[to])
+				freshFrom, freshTo := fresh.airportsByName[leftNames[from][0]], fresh.airportsByName[leftNames[to][0]]
+				_, expected, expectedOk, _ := fresh.traverse(&freshPlan, freshFrom, freshTo)
+				if ok != expectedOk || math.Abs(cost-expected) > 1e-6 {
+					t.Errorf("plan %d: %s to %s costs %f (%t) edited (overlay %t) but %f (%t) built afresh",
+						p, left[from], left[to], cost, ok, *overlay, expected, expectedOk)
+				}
+			}
+		}
+	}
+}
+
+// TestCriteria checks that flights of random cases by -criteria distance
+// cost as much as usual, and that Pareto fronts of distance and landings
+// begin with the shortest route and hold no route another beats.
+func TestCriteria(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	config := loadSettings()
+	byCriteria := *config
+	byCriteria.criteria = parseCriteria("distance", 0)
+	byCriteria.pareto = parseCriteria("distance, landings", 2)
+	for set := 0; set < 4; set++ {
+		c := randomCase(r, config)
+		for flight := 0; flight < 10; flight++ {
+			from, to, planeRange := randomFlight(r, c)
+			plan := &flightPlan{planeRange, config.profile, false, 0.0, false, false, nil, false, nil}
+			_, expected, expectedOk := c.graph.Traverse(newFlightState(plan), from, to)
+
+			c.settings = &byCriteria
+			route, cost, ok, _ := c.traverse(plan, from, to)
+			c.settings = config
+			if ok != expectedOk || math.Abs(cost-expected) > 1e-6 {
+				t.Errorf("set %d: %s to %s with range %f costs %f (%t) by criteria but %f (%t) otherwise",
+					set, from, to, planeRange, cost, ok, expected, expectedOk)
+				continue
+			}
+			if ok && math.Abs(routeCosts(route, plan, byCriteria.criteria)[0]-cost) > 1e-6 {
+				t.Errorf("set %d: route %v does not cost %f", set, route, cost)
+			}
+
+			front, _ := c.graph.ParetoFront(context.Background(), newFlightState(plan), from, to, byCriteria.pareto[0].price, byCriteria.pareto[1].price, 0)
+			if len(front) == 0 {
+				if expectedOk {
+					t.Errorf("set %d: %s to %s with range %f has no Pareto front", set, from, to, planeRange)
+				}
+				continue
+			}
+			if math.Abs(front[0].Costs[0]-expected) > 1e-6 {
+				t.Errorf("set %d: %s to %s with range %f has a Pareto front from %f rather than %f",
+					set, from, to, planeRange, front[0].Costs[0], expected)
+			}
+			for i, p := range front {
+				costs := routeCosts(p.Path, plan, byCriteria.pareto)
+				if math.Abs(costs[0]-p.Costs[0]) > 1e-6 || costs[1] != p.Costs[1] {
+					t.Errorf("set %d: route %v costs %v rather than %v", set, p.Path, costs, p.Costs)
+				}
+				if i > 0 && (p.Costs[0] < front[i-1].Costs[0] || p.Costs[1] >= front[i-1].Costs[1]) {
+					t.Errorf("set %d: routes costing %v and %v are both in the Pareto front", set, front[i-1].Costs, p.Costs)
+				}
+			}
+		}
+	}
+}
+
+// TestParetoWater flies between two airports whose circles meet east and
+// west of the way between them, with land to the east, so that the front
+// of distance and distance over water is the way straight over the water
+// and the way east over land, beating the way west. Too short a range
+// leaves only the straight way. The way east reaches 1.81 degrees east, so
+// is over land east of 0.5 degrees for all but about 166 km, which is 170
+// measured in WATER_STEP_KM steps.
+func TestParetoWater(t *testing.T) {
+	defer func(saved []*zone) { land = saved }(land)
+	land = []*zone{{name: "east", polygon: []*sphere.NVector{
+		sphere.NewNVectorFromLatLongDeg(-1.0, 0.5), sphere.NewNVectorFromLatLongDeg(-1.0, 5.0),
+		sphere.NewNVectorFromLatLongDeg(5.0, 5.0), sphere.NewNVectorFromLatLongDeg(5.0, 0.5)}}}
+
+	input := `2 300
+0 0
+0 4
+2
+1 2 1000
+1 2 500
+`
+	expected := `Case 1:
+2 routes
+    distance 444.710, water 444.710
+    distance 600.000, water 170.000
+1 route
+    distance 444.710, water 444.710
+`
+	defer func(saved bool) { *printRoute = saved }(*printRoute)
+	*printRoute = false
+	config := loadSettings()
+	config.pareto = parseCriteria("distance,water", 2)
+	var out bytes.Buffer
+	run(config, bufio.NewReader(strings.NewReader(input)), &out, "", nil)
+	if out.String() != expected {
+		t.Errorf("the front is\n%s\nrather than\n%s", out.String(), expected)
+	}
+}
+
+// fuelProfile burns 100 kg an hour at 100 km/h, with no climb, descent or
+// turnaround, and holds 500 kg, of which 100 kg is its hour's reserve.
+func fuelProfile() *aircraftProfile {
+	return &aircraftProfile{name: "fuel", cruiseSpeedKmh: 100.0, burnKgPerHour: 100.0, tankKg: 500.0, reserveMins: 60.0}
+}
+
+func TestPlanRefuelling(t *testing.T) {
+	airport := func(name string, price float64) *Airport {
+		return &Airport{name: name, hasFuel: price >= 0, fuelPrice: price}
+	}
+	a, b, c, d := airport("A", 2.0), airport("B", 1.0), airport("C", 3.0), airport("D", 1.0)
+	dry, dear := airport("dry", -1.0), airport("dear", 2.0)
+
+	tests := []struct {
+		what     string
+		legs     []leg
+		expected []refuel
+	}{
+		{"just enough to reach cheaper fuel, then enough to finish",
+			[]leg{{from: a, to: b, airKm: 100.0}, {from: b, to: c, airKm: 200.0}, {from: c, to: d, airKm: 100.0}},
+			[]refuel{{a, 100.0}, {b, 300.0}}},
+		{"full from an origin without fuel",
+			[]leg{{from: dry, to: dear, airKm: 300.0}, {from: dear, to: b, airKm: 300.0}, {from: b, to: d, airKm: 100.0}},
+			[]refuel{{dear, 200.0}, {b, 100.0}}},
+		{"full with nothing cheaper in reach",
+			[]leg{{from: b, to: dear, airKm: 300.0}, {from: dear, to: c, airKm: 300.0}},
+			[]refuel{{b, 400.0}, {dear, 200.0}}},
+	}
+	for _, test := range tests {
+		plan := planRefuelling(test.legs, fuelProfile())
+		if len(plan) != len(test.expected) {
+			t.Errorf("%s: plan %v rather than %v", test.what, plan, test.expected)
+			continue
+		}
+		for i, r := range plan {
+			if r.airport != test.expected[i].airport || math.Abs(r.kg-test.expected[i].kg) > 1e-9 {
+				t.Errorf("%s: plan %v rather than %v", test.what, plan, test.expected)
+				break
+			}
+		}
+	}
+}
+
+// TestFuelReserve flies out of an airport to a point and on to airports
+// with and without fuel, one of them too far to reach with the reserve
+// left.
+func TestFuelReserve(t *testing.T) {
+	origin := &Airport{name: "origin", hasFuel: true}
+	wet, dry, far := &Airport{name: "wet", hasFuel: true}, &Airport{name: "dry"}, &Airport{name: "far", hasFuel: true}
+	graph := g.NewGraph[place, flightState]()
+	originNode, point := graph.NewNode(origin), graph.NewNode(&AirportIntersection{airports: [2]*Airport{origin, wet}})
+	wetNode, dryNode, farNode := graph.NewNode(wet), graph.NewNode(dry), graph.NewNode(far)
+	graph.ConnectUni(originNode, point, 200.0)
+	graph.ConnectUni(point, wetNode, 200.0)
+	graph.ConnectUni(point, dryNode, 150.0)
+	graph.ConnectUni(point, farNode, 201.0)
+	graph.Freeze()
+
+	plan := &flightPlan{1000.0, fuelProfile(), false, 0.0, false, false, nil, false, nil}
+	fs, ok := newFlightState(plan).TraverseStateHelper(originNode.VertexTo(point))
+	if !ok || fs.fuelKg != 300.0 || fs.remainingRange != 800.0 {
+		t.Fatalf("flying 200 km leaves %f kg and %f km (%t) rather than 300 kg and 800 km", fs.fuelKg, fs.remainingRange, ok)
+	}
+	if landed, ok := fs.TraverseStateHelper(point.VertexTo(wetNode)); !ok || landed.fuelKg != 500.0 || landed.remainingRange != 1000.0 {
+		t.Errorf("landing with the reserve where there is fuel leaves %f kg and %f km (%t) rather than a full tank and range", landed.fuelKg, landed.remainingRange, ok)
+	}
+	if landed, ok := fs.TraverseStateHelper(point.VertexTo(dryNode)); !ok || landed.fuelKg != 150.0 || landed.remainingRange != 650.0 {
+		t.Errorf("landing where there is no fuel leaves %f kg and %f km (%t) rather than 150 kg and 650 km", landed.fuelKg, landed.remainingRange, ok)
+	}
+	if _, ok := fs.TraverseStateHelper(point.VertexTo(farNode)); ok {
+		t.Errorf("landing into the reserve allowed")
+	}
+	if _, _, ok := graph.Traverse(newFlightState(plan), originNode, farNode); ok {
+		t.Errorf("route found that lands into the reserve")
+	}
+}
+
+func TestFuelDominates(t *testing.T) {
+	plan := &flightPlan{1000.0, fuelProfile(), false, 0.0, false, false, nil, false, nil}
+	state := func(rangeKm, fuelKg float64, atOrigin bool) flightState {
+		return flightState{plan, rangeKm, fuelKg, 0.0, 0.0, atOrigin, false}
+	}
+	dear := state(800.0, 300.0, false)
+	dear.fuelPrice = 2.0
+	tests := []struct {
+		what          string
+		state, other  flightState
+		dominates     bool
+		isDominatedBy bool
+	}{
+		{"more fuel", state(800.0, 300.0, false), state(800.0, 200.0, false), true, false},
+		{"the same", state(800.0, 300.0, false), state(800.0, 300.0, false), true, true},
+		{"more fuel, less range", state(700.0, 400.0, false), state(800.0, 300.0, false), false, false},
+		{"at the origin", state(800.0, 300.0, true), state(800.0, 300.0, false), true, false},
+		{"cheaper fuel", state(800.0, 300.0, false), dear, true, false},
+		{"more but dearer fuel", state(800.0, 200.0, false), dear, false, false},
+	}
+	for _, test := range tests {
+		if test.state.Dominates(test.other) != test.dominates || test.other.Dominates(test.state) != test.isDominatedBy {
+			t.Errorf("%s: dominates %t and is dominated %t rather than %t and %t", test.what,
+				test.state.Dominates(test.other), test.other.Dominates(test.state), test.dominates, test.isDominatedBy)
+		}
+	}
+}
+
+// TestFuelCost flies between airports 889 km apart with too little fuel to
+// go without a stop, by way of an airport on the straight line selling fuel
+// at 5 a kg or one a little off it selling fuel at 1. Fuel from the origin
+// costs 3. Going by distance stops on the line, going by fuel cost off it.
+func TestFuelCost(t *testing.T) {
+	defer func(savedBurn, savedTank float64) { *burnRate, *tankCapacity = savedBurn, savedTank }(*burnRate, *tankCapacity)
+	defer func(saved bool) { *printRoute = saved }(*printRoute)
+	*burnRate, *tankCapacity, *printRoute = 1000.0, 2000.0, false
+
+	fileName := filepath.Join(t.TempDir(), "fuel.txt")
+	contents := `"Airport 1" 3
+# dear
+"Airport 3" 5
+
+"Airport 4" 1
+`
+	if err := ioutil.WriteFile(fileName, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := `4 600
+0 0
+8 0
+4 0
+4 2
+1
+1 2 3000
+`
+	cases := []struct {
+		criteria, expected string
+	}{
+		{"distance,fuelcost", `Case 1:
+889.420
+    refuel 1250.0 kg at Airport 1 for 3750.00
+    refuel 528.4 kg at Airport 3 for 2642.21
+    fuel cost: 6392.21
+    distance 889.420, fuelcost 7113.766
+`},
+		{"fuelcost,distance", `Case 1:
+3818.933
+    refuel 954.7 kg at Airport 1 for 2864.20
+    refuel 954.7 kg at Airport 4 for 954.73
+    fuel cost: 3818.93
+    fuelcost 3818.933, distance 994.240
+`},
+	}
+	for _, tc := range cases {
+		config := loadSettings()
+		config.airportData.fuelPrices = readFuelPrices(fileName)
+		config.criteria = parseCriteria(tc.criteria, 0)
+		var out bytes.Buffer
+		run(config, bufio.NewReader(strings.NewReader(input)), &out, "", nil)
+		if out.String() != tc.expected {
+			t.Errorf("by %s the flight gives\n%s\nrather than\n%s", tc.criteria, out.String(), tc.expected)
+		}
+	}
+}
+
+func TestParseHHMM(t *testing.T) {
+	cases := []struct {
+		text    string
+		minutes int
+		ok      bool
+	}{
+		{"0000", 0, true},
+		{"0930", 570, true},
+		{"2359", 1439, true},
+		{"2400", 1440, true},
+		{"2401", 0, false},
+		{"2459", 0, false},
+		{"2500", 0, false},
+		{"1260", 0, false},
+		{"-100", 0, false},
+		{"+100", 0, false},
+		{"12ab", 0, false},
+		{"1 30", 0, false},
+		{"930", 0, false},
+		{"09300", 0, false},
+	}
+	for _, tc := range cases {
+		if minutes, ok := parseHHMM(tc.text); ok != tc.ok || (ok && minutes != tc.minutes) {
+			t.Errorf("parseHHMM(%q) = %d, %t rather than %d, %t", tc.text, minutes, ok, tc.minutes, tc.ok)
+		}
+	}
+}
+
+func TestIsOpen(t *testing.T) {
+	daytime := &openingHours{8 * 60, 17 * 60, -5.0}  // 1300 to 2200 UTC
+	overnight := &openingHours{22 * 60, 6 * 60, 1.0} // 2100 to 0500 UTC
+	cases := []struct {
+		hours    *openingHours
+		utcHours float64
+		open     bool
+	}{
+		{daytime, 12.9, false},
+		{daytime, 13.0, true},
+		{daytime, 22.0, true},
+		{daytime, 22.1, false},
+		{daytime, 24.0 + 13.5, true},
+		{daytime, 3.0, false},
+		{overnight, 20.9, false},
+		{overnight, 21.0, true},
+		{overnight, 23.5, true},
+		{overnight, 24.0 + 4.5, true},
+		{overnight, 5.5, false},
+	}
+	for _, tc := range cases {
+		if open := tc.hours.isOpen(tc.utcHours); open != tc.open {
+			t.Errorf("%+v at %f UTC is open %t rather than %t", *tc.hours, tc.utcHours, open, tc.open)
+		}
+		if wait := (availability{false, tc.hours}).waitHours(tc.utcHours); (wait == 0.0) != tc.open {
+			t.Errorf("%+v at %f UTC means waiting %f", *tc.hours, tc.utcHours, wait)
+		}
+	}
+
+	waits := []struct {
+		hours          *openingHours
+		utcHours, wait float64
+	}{
+		{daytime, 12.5, 0.5},
+		{daytime, 23.0, 14.0},
+		{overnight, 5.5, 15.5},
+		{overnight, 24.0 + 20.0, 1.0},
+	}
+	for _, w := range waits {
+		if wait := (availability{false, w.hours}).waitHours(w.utcHours); math.Abs(wait-w.wait) > 1e-9 {
+			t.Errorf("%+v at %f UTC means waiting %f rather than %f", *w.hours, w.utcHours, wait, w.wait)
+		}
+	}
+}
+
+func TestReadAvailability(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "avail.txt")
+	contents := `# closures and opening hours
+"AAA" closed
+
+"BBB" 0800 1700 -5
+"C C" 2200 0600 1.5
+`
+	if err := ioutil.WriteFile(fileName, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got := readAvailability(fileName)
+	expected := map[string]availability{
+		"AAA": {true, nil},
+		"BBB": {false, &openingHours{480, 1020, -5.0}},
+		"C C": {false, &openingHours{1320, 360, 1.5}},
+	}
+	if len(got) != len(expected) {
+		t.Errorf("read %d airports rather than %d", len(got), len(expected))
+	}
+	for name, e := range expected {
+		a, found := got[name]
+		if !found || a.closed != e.closed || (a.hours == nil) != (e.hours == nil) || (a.hours != nil && *a.hours != *e.hours) {
+			t.Errorf("%s has availability %+v rather than %+v", name, a, e)
+		}
+	}
+	if !hasOpeningHours(got) || hasOpeningHours(map[string]availability{"AAA": {true, nil}}) {
+		t.Errorf("opening hours found where there are none, or missed")
+	}
+
+	for _, bad := range []string{`"DDD" 0800 2460 0`, `"DDD" 8am 1700 0`, `"DDD" 0800`, `DDD closed`} {
+		if err := ioutil.WriteFile(fileName, []byte(bad+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("read %q without complaint", bad)
+				}
+			}()
+			readAvailability(fileName)
+		}()
+	}
+}
+
+// TestAvailability flies along a line of three airports, too far apart for
+// the aircraft to skip the middle one, with closures and opening hours.
+func TestAvailability(t *testing.T) {
+	input := `3 700
+0 0
+0 10
+0 20
+2
+1 3 1500
+3 1 1500
+`
+	cases := []struct {
+		availability map[string]availability
+		minimizeTime bool
+		expected     string
+	}{
+		{map[string]availability{"Airport 2": {true, nil}}, false, `Case 1:
+impossible (Airport 2 is closed)
+impossible (Airport 2 is closed)
+`},
+		// without minimizing time the wait makes no difference
+		{map[string]availability{"Airport 2": {false, &openingHours{12 * 60, 13 * 60, 0.0}}}, false, `Case 1:
+2223.549
+Airport 1
+Airport 2
+Airport 3
+2223.549
+Airport 3
+Airport 2
+Airport 1
+`},
+		// waiting for the origin to open, and on the way back for it to
+		// be open on landing
+		{map[string]availability{"Airport 1": {false, &openingHours{6 * 60, 18 * 60, 0.0}}}, true, `Case 1:
+2223.549
+    Airport 1 -> Airport 2: 1111.775 km, 1:43 after waiting 6:00
+    Airport 2 -> Airport 3: 1111.775 km, 1:43
+    block time: 4:12
+    waiting: 6:00
+Airport 1
+Airport 2
+Airport 3
+2223.549
+    Airport 3 -> Airport 2: 1111.775 km, 1:43
+    Airport 2 -> Airport 1: 1111.775 km, 1:43 after waiting 1:48
+    block time: 4:12
+    waiting: 1:48
+Airport 3
+Airport 2
+Airport 1
+`},
+	}
+	defer func(saved bool) { *overlay = saved }(*overlay)
+	for _, *overlay = range []bool{false, true} {
+		for _, tc := range cases {
+			config := loadSettings()
+			config.airportData = &airportData{map[string][]runway{}, tc.availability, map[string]float64{}}
+			config.openingHours, config.closures = hasOpeningHours(tc.availability), hasClosures(tc.availability)
+			config.minimizeTime = tc.minimizeTime
+			var out bytes.Buffer
+			run(config, bufio.NewReader(strings.NewReader(input)), &out, "", nil)
+			if out.String() != tc.expected {
+				t.Errorf("flights with availability %v (overlay %t) give\n%s\nrather than\n%s", tc.availability, *overlay, out.String(), tc.expected)
+			}
+		}
+	}
+}
+
+// TestItineraryOpeningHours flies an itinerary whose last airport opens
+// after the first segment's time, so only an aircraft that has already
+// flown the first segment and turned around finds it open on landing.
+func TestItineraryOpeningHours(t *testing.T) {
+	input := `3 700
+0 0
+0 10
+0 20
+1
+1 -> 2 -> 3 1500
+`
+	expected := `Case 1:
+4:12
+    Airport 1 -> Airport 2: 1:43
+    Airport 2 -> Airport 3: 1:43
+    turnarounds: 0:45
+`
+	defer func(saved bool) { *printRoute = saved }(*printRoute)
+	*printRoute = false
+	availability := map[string]availability{"Airport 3": {false, &openingHours{3 * 60, 18 * 60, 0.0}}}
+	config := loadSettings()
+	config.airportData = &airportData{map[string][]runway{}, availability, map[string]float64{}}
+	config.openingHours, config.minimizeTime = true, true
+	var out bytes.Buffer
+	run(config, bufio.NewReader(strings.NewReader(input)), &out, "", nil)
+	if out.String() != expected {
+		t.Errorf("an itinerary to an airport opening at 0300 gives\n%s\nrather than\n%s", out.String(), expected)
+	}
+}
+
+func TestCrosswind(t *testing.T) {
+	cases := []struct {
+		u, v, headingDeg, expected float64
+	}{
+		{20.0, 0.0, 0.0, 20.0},    // easterly across a north-south runway
+		{0.0, 30.0, 180.0, 0.0},   // straight down it
+		{20.0, 0.0, 90.0, 0.0},    // along an east-west runway
+		{0.0, -30.0, 270.0, 30.0}, // across it from the north
+		{10.0, 0.0, 45.0, 10.0 * math.Sqrt(0.5)},
+	}
+	for _, tc := range cases {
+		if got := crosswindKmh(tc.u, tc.v, tc.headingDeg); math.Abs(got-tc.expected) > 1e-9 {
+			t.Errorf("wind (%.0f, %.0f) across heading %.0f is %f rather than %f", tc.u, tc.v, tc.headingDeg, got, tc.expected)
+		}
+	}
+}
+
+func TestReadProfiles(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "profiles.txt")
+	contents := `# name range cruise runway crosswind diversion
+"jet" 5000 800 6000 40 100
+
+"bush plane" 1500 300 0 0 30min
+`
+	if err := ioutil.WriteFile(fileName, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	base := fuelProfile()
+	got := readProfiles(fileName, base)
+	expected := map[string]aircraftProfile{
+		"jet":        {"jet", 5000.0, 800.0, 0.0, 0.0, 100.0, 500.0, 60.0, 6000.0, 40.0, 100.0, 0},
+		"bush plane": {"bush plane", 1500.0, 300.0, 0.0, 0.0, 100.0, 500.0, 60.0, 0.0, 0.0, 150.0, 0},
+	}
+	if len(got) != len(expected) {
+		t.Errorf("read %d profiles rather than %d", len(got), len(expected))
+	}
+	for name, e := range expected {
+		if p, found := got[name]; !found || *p != e {
+			t.Errorf("%s is %+v rather than %+v", name, p, e)
+		}
+	}
+	if base.name != "fuel" || base.rangeKm != 0.0 {
+		t.Errorf("reading profiles changed the base profile to %+v", base)
+	}
+
+	for _, bad := range []string{`"x" 0 800 0 0 0`, `"x" 1000 800 0 0 far`, `"x" 1000 800 0 0 longmin`, `x 1000 800 0 0 0`, `"x" 1000 800`} {
+		if err := ioutil.WriteFile(fileName, []byte(bad+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("read %q without complaint", bad)
+				}
+			}()
+			readProfiles(fileName, base)
+		}()
+	}
+}
+
+func TestReadAttributes(t *testing.T) {
+	defer func(saved bool) { haveRunwayData = saved }(haveRunwayData)
+	haveRunwayData = false
+
+	fileName := filepath.Join(t.TempDir(), "attrs.txt")
+	contents := `# runways
+"A A" 8000 90 3000 -1
+
+"B"
+`
+	if err := ioutil.WriteFile(fileName, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got := readAttributes(fileName)
+	if !haveRunwayData {
+		t.Errorf("reading attributes does not note that runways are known")
+	}
+	expected := map[string][]runway{
+		"A A": {{8000.0, 90.0}, {3000.0, -1.0}},
+		"B":   {},
+	}
+	if len(got) != len(expected) {
+		t.Errorf("read %d airports rather than %d", len(got), len(expected))
+	}
+	for name, e := range expected {
+		runways, found := got[name]
+		if !found || len(runways) != len(e) {
+			t.Errorf("%s has runways %v rather than %v", name, runways, e)
+			continue
+		}
+		for i := range e {
+			if runways[i] != e[i] {
+				t.Errorf("%s has runways %v rather than %v", name, runways, e)
+				break
+			}
+		}
+	}
+
+	for _, bad := range []string{`"C" 8000`, `"C" long 90`, `C 8000 90`} {
+		if err := ioutil.WriteFile(fileName, []byte(bad+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("read %q without complaint", bad)
+				}
+			}()
+			readAttributes(fileName)
+		}()
+	}
+}
+
+// uniformWinds returns a grid around the origin on which the wind is the
+// same everywhere.
+func uniformWinds(t *testing.T, u, v float64) *wind.Grid {
+	var grid strings.Builder
+	for _, lat := range []int{-10, 10} {
+		for _, lon := range []int{-10, 10} {
+			fmt.Fprintf(&grid, "%d,%d,%f,%f\n", lat, lon, u, v)
+		}
+	}
+	winds, err := wind.ReadGrid(strings.NewReader(grid.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return winds
+}
+
+// TestCanLandAt lands on an east-west runway in a north wind, which only
+// counts against the crosswind limit when it blows at the surface.
+func TestCanLandAt(t *testing.T) {
+	defer func(savedData bool, savedWinds, savedSurface *wind.Grid) {
+		haveRunwayData, winds, surfaceWinds = savedData, savedWinds, savedSurface
+	}(haveRunwayData, winds, surfaceWinds)
+	haveRunwayData, winds, surfaceWinds = true, nil, nil
+
+	jet := &aircraftProfile{name: "jet", rangeKm: 5000.0, cruiseSpeedKmh: 800.0, minRunwayFt: 6000.0, maxCrosswindKmh: 40.0}
+	airport := &Airport{NVector: *sphere.NewNVectorFromLatLongDeg(0.0, 0.0), name: "A", runways: []runway{{8000.0, 90.0}}}
+	if !jet.canLandAt(airport) {
+		t.Errorf("jet cannot land in calm air")
+	}
+	if jet.canLandAt(&Airport{name: "B", runways: []runway{{5000.0, 90.0}}}) || jet.canLandAt(&Airport{name: "C"}) {
+		t.Errorf("jet lands on a short or unknown runway")
+	}
+
+	winds = uniformWinds(t, 0.0, -50.0)
+	if !jet.canLandAt(airport) {
+		t.Errorf("jet cannot land for wind aloft")
+	}
+	surfaceWinds = uniformWinds(t, 0.0, -50.0)
+	if jet.canLandAt(airport) {
+		t.Errorf("jet lands in a 50 km/h crosswind")
+	}
+	surfaceWinds = uniformWinds(t, 60.0, -30.0)
+	if !jet.canLandAt(airport) {
+		t.Errorf("jet cannot land in a 30 km/h crosswind")
+	}
+
+	surfaceWinds = uniformWinds(t, 0.0, -50.0)
+	input := `2 2000
+0 0
+0 5
+1
+1 2 jet
+`
+	expected := `Case 1:
+impossible (Airport 1 is unsuitable for jet)
+`
+	config := loadSettings()
+	config.profiles = map[string]*aircraftProfile{"jet": jet}
+	config.airportData = &airportData{map[string][]runway{"Airport 1": {{8000.0, 90.0}}, "Airport 2": {{8000.0, 0.0}}}, map[string]availability{}, map[string]float64{}}
+	var out bytes.Buffer
+	run(config, bufio.NewReader(strings.NewReader(input)), &out, "", nil)
+	if out.String() != expected {
+		t.Errorf("flying in a crosswind gives\n%s\nrather than\n%s", out.String(), expected)
+	}
+}
+
+// TestProfileWinds flies east and back west through a 100 km/h west wind
+// with aircraft of different cruise speeds, each of which must be charged
+// the air distance at its own speed, over the network and its overlay. The
+// slowest cannot make way against the wind at all.
+func TestProfileWinds(t *testing.T) {
+	defer func(savedWinds *wind.Grid, savedSpeeds []float64, savedOverlay bool) {
+		winds, windSpeeds, *overlay = savedWinds, savedSpeeds, savedOverlay
+	}(winds, windSpeeds, *overlay)
+	winds = uniformWinds(t, 100.0, 0.0)
+
+	profiles := map[string]*aircraftProfile{
+		"jet":  {name: "jet", rangeKm: 5000.0, cruiseSpeedKmh: *cruiseSpeed},
+		"prop": {name: "prop", rangeKm: 5000.0, cruiseSpeedKmh: 300.0},
+		"kite": {name: "kite", rangeKm: 5000.0, cruiseSpeedKmh: 90.0},
+	}
+	config := loadSettings()
+	windSpeeds = assignWindColumns(profiles)
+	if profiles["jet"].windColumn != 0 || profiles["prop"].windColumn == 0 || profiles["kite"].windColumn == profiles["prop"].windColumn {
+		t.Fatalf("profiles were given wind columns %d, %d and %d", profiles["jet"].windColumn, profiles["prop"].windColumn, profiles["kite"].windColumn)
+	}
+
+	nw := newNetwork(300.0)
+	airports := []*Airport{
+		config.newAirport(sphere.NewNVectorFromLatLongDeg(0.0, 0.0), []string{"W"}),
+		config.newAirport(sphere.NewNVectorFromLatLongDeg(0.0, 4.0), []string{"E"}),
+	}
+	nw.addAirports(airports, [][]string{{"W"}, {"E"}}, 1)
+	c := &caseContext{config, nw, ioutil.Discard, nil}
+	west, east := c.lookup("1"), c.lookup("2")
+
+	for _, *overlay = range []bool{false, true} {
+		c.overlays = make(map[overlayKey]*airportOverlay)
+		costs := make(map[string]float64)
+		for name, profile := range profiles {
+			for _, ends := range [][2]*placeNode{{west, east}, {east, west}} {
+				route, cost, _, failure := c.fly(ends[0], ends[1], profile, profile.rangeKm)
+				if name == "kite" && ends[0] == east {
+					if failure != "impossible" {
+						t.Errorf("%s flies into the wind from %s to %s (%s)", name, ends[0].Record, ends[1].Record, failure)
+					}
+					continue
+				}
+				if failure != "" {
+					t.Errorf("%s cannot fly from %s to %s (%s)", name, ends[0].Record, ends[1].Record, failure)
+					continue
+				}
+				expected := 0.0
+				for i := 1; i < len(route); i++ {
+					v1, v2 := route[i-1].Record.Location(), route[i].Record.Location()
+					airKm, _ := winds.AirDistance(&v1, &v2, EARTH_RADIUS_KM, profile.cruiseSpeedKmh)
+					expected += airKm
+				}
+				if math.Abs(cost-expected) > 1e-6 {
+					t.Errorf("%s from %s to %s costs %f rather than %f (overlay %t)", name, ends[0].Record, ends[1].Record, cost, expected, *overlay)
+				}
+				costs[name+" from "+ends[0].Record.String()] = cost
+			}
+		}
+		if costs["prop from "+west.Record.String()] >= costs["jet from "+west.Record.String()] ||
+			costs["prop from "+east.Record.String()] <= costs["jet from "+east.Record.String()] {
+			t.Errorf("the wind makes no more difference to the slower aircraft: %v", costs)
+		}
+	}
+}
+
+// TestBlockTime flies from A through a point to B and on to C, at 100
+// km/h with half an hour's climb and descent per leg and an hour's
+// turnaround. Summing the vertices' hours must agree with the block time
+// printed for the legs.
+func TestBlockTime(t *testing.T) {
+	profile := &aircraftProfile{name: "slow", cruiseSpeedKmh: 100.0, climbDescentMins: 30.0, turnaroundMins: 60.0}
+	airport := func(name string, lon float64) *Airport {
+		return &Airport{NVector: *sphere.NewNVectorFromLatLongDeg(0.0, lon), name: name}
+	}
+	a, b, c := airport("A", 0.0), airport("B", 0.9), airport("C", 2.7)
+
+	graph := g.NewGraph[place, flightState]()
+	aNode, bNode, cNode := graph.NewNode(a), graph.NewNode(b), graph.NewNode(c)
+	point := graph.NewNode(&AirportIntersection{*sphere.NewNVectorFromLatLongDeg(0.0, 0.45), [2]*Airport{a, b}})
+	graph.ConnectBi(aNode, point, 100.0)
+	graph.ConnectBi(point, bNode, 100.0)
+	graph.ConnectBi(bNode, cNode, 200.0)
+	graph.Freeze()
+
+	route := []*placeNode{aNode, point, bNode, cNode}
+	expected := []float64{1.0, 1.5, 3.5}
+	total := 0.0
+	fs := newFlightState(&flightPlan{fullRange: 1000.0, profile: profile, minimizeTime: true})
+	for i, hours := range expected {
+		v := route[i].VertexTo(route[i+1])
+		if got := fs.VertexCost(v); math.Abs(got-hours) > 1e-9 {
+			t.Errorf("%s to %s takes %f hours rather than %f", v.From.Record, v.To.Record, got, hours)
+		}
+		total += hours
+		fs, _ = fs.TraverseStateHelper(v)
+	}
+	if got := profile.vertexHours(bNode.VertexTo(cNode), true); math.Abs(got-2.5) > 1e-9 {
+		t.Errorf("leaving the origin takes %f hours rather than 2.5 without turnaround", got)
+	}
+
+	var out bytes.Buffer
+	printBlockTimes(&out, splitLegs(route, profile), &flightPlan{profile: profile})
+	expectedOut := fmt.Sprintf(`    A -> B: 100.060 km, 2:30
+    B -> C: 200.119 km, 2:30
+    block time: %s
+`, formatHours(total))
+	if out.String() != expectedOut {
+		t.Errorf("block times are\n%s\nrather than\n%s", out.String(), expectedOut)
+	}
+}
+
+// TestMinimizeTime flies between two airports 1112 km apart with a 600 km
+// range. Stopping twice on the straight line between them is shortest, but
+// at 800 km/h a single stop a little off the line is quicker:
+//
+//	two stops: 1112/800 h + 3 x 0:20 climb + 2 x 0:45 turnaround = 3:53
+//	one stop:  1161/800 h + 2 x 0:20 climb + 0:45 turnaround = 2:52
+func TestMinimizeTime(t *testing.T) {
+	input := `5 300
+0 0
+10 0
+5 1.5
+3.4 0
+6.7 0
+1
+1 2 600
+`
+	cases := []struct {
+		minimizeTime bool
+		expected     string
+	}{
+		{false, `Case 1:
+1111.775
+Airport 1
+Airport 4
+Airport 5
+Airport 2
+`},
+		{true, `Case 1:
+1160.605
+    Airport 1 -> Airport 3: 580.303 km, 1:04
+    Airport 3 -> Airport 2: 580.303 km, 1:04
+    block time: 2:52
+Airport 1
+Airport 3
+Airport 2
+`},
+	}
+	for _, tc := range cases {
+		config := loadSettings()
+		config.minimizeTime = tc.minimizeTime
+		var out bytes.Buffer
+		run(config, bufio.NewReader(strings.NewReader(input)), &out, "", nil)
+		if out.String() != tc.expected {
+			t.Errorf("minimizing time %t gives\n%s\nrather than\n%s", tc.minimizeTime, out.String(), tc.expected)
+		}
+	}
+}
+
+func TestParseItinerary(t *testing.T) {
+	cases := []struct {
+		line     string
+		expected []stage
+	}{
+		{"A -> B", []stage{{"A"}, {"B"}}},
+		{"A -> [ B C ] -> D", []stage{{"A"}, {"B", "C"}, {"D"}}},
+		{"A -> [ B ] -> C -> [ D E F ]", []stage{{"A"}, {"B"}, {"C"}, {"D", "E", "F"}}},
+	}
+	for _, tc := range cases {
+		got := parseItinerary(strings.Fields(tc.line))
+		if fmt.Sprint(got) != fmt.Sprint(tc.expected) {
+			t.Errorf("%q parses as %v rather than %v", tc.line, got, tc.expected)
+		}
+	}
+
+	for _, bad := range []string{"A", "A B", "A ->", "-> A", "[ A B ] -> C", "A -> [ ]", "A -> [ B C",
+		"A -> [ B C ] -> [ D ]", "A -> ] -> B", "A -> -> B"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("parsed %q without complaint", bad)
+				}
+			}()
+			parseItinerary(strings.Fields(bad))
+		}()
+	}
+}
+
+// TestItinerary flies itineraries along a line of four airports 111 km
+// apart, with sets given out of order. At 800 km/h with 20 minutes' climb
+// and descent each leg is 0:28, or 0:37 when it spans two gaps, and the
+// total adds 45 minutes' turnaround at each stop between segments.
+func TestItinerary(t *testing.T) {
+	input := `4 300
+0 0
+1 0
+2 0
+3 0
+3
+1 -> [ 3 2 ] -> 4 500
+1 -> [ 4 2 ] 500
+1 -> [ 4 3 2 ] 500
+`
+	cases := []struct {
+		minimizeTime bool
+		expected     string
+	}{
+		{false, `Case 1:
+333.532
+    Airport 1 -> Airport 2: 111.177
+    Airport 2 -> Airport 3: 111.177
+    Airport 3 -> Airport 4: 111.177
+333.532
+    Airport 1 -> Airport 2: 111.177
+    Airport 2 -> Airport 4: 222.355
+333.532
+    Airport 1 -> Airport 2: 111.177
+    Airport 2 -> Airport 3: 111.177
+    Airport 3 -> Airport 4: 111.177
+`},
+		{true, `Case 1:
+2:55
+    Airport 1 -> Airport 2: 0:28
+    Airport 2 -> Airport 3: 0:28
+    Airport 3 -> Airport 4: 0:28
+    turnarounds: 1:30
+1:50
+    Airport 1 -> Airport 2: 0:28
+    Airport 2 -> Airport 4: 0:37
+    turnarounds: 0:45
+2:55
+    Airport 1 -> Airport 2: 0:28
+    Airport 2 -> Airport 3: 0:28
+    Airport 3 -> Airport 4: 0:28
+    turnarounds: 1:30
+`},
+	}
+	defer func(saved bool) { *printRoute = saved }(*printRoute)
+	*printRoute = false
+	for _, tc := range cases {
+		config := loadSettings()
+		config.minimizeTime = tc.minimizeTime
+		var out bytes.Buffer
+		run(config, bufio.NewReader(strings.NewReader(input)), &out, "", nil)
+		if out.String() != tc.expected {
+			t.Errorf("minimizing time %t gives\n%s\nrather than\n%s", tc.minimizeTime, out.String(), tc.expected)
+		}
+	}
+}
+
+// TestLargeItinerarySet flies along a line of airports with a set of more
+// airports than tour.ExactPath takes, which must still be ordered along the
+// line.
+func TestLargeItinerarySet(t *testing.T) {
+	count := tour.MAX_EXACT_STOPS + 2
+	var input, set strings.Builder
+	fmt.Fprintf(&input, "%d 300\n", count)
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&input, "%d 0\n", i)
+	}
+	for i := count; i > 1; i-- {
+		fmt.Fprintf(&set, " %d", i)
+	}
+	fmt.Fprintf(&input, "1\n1 -> [%s ] 500\n", set.String())
+
+	defer func(saved bool) { *printRoute = saved }(*printRoute)
+	*printRoute = false
+	var out bytes.Buffer
+	run(loadSettings(), bufio.NewReader(strings.NewReader(input.String())), &out, "", nil)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != count+1 {
+		t.Fatalf("an itinerary through %d airports gives\n%s", count, out.String())
+	}
+	for i, line := range lines[2:] {
+		if prefix := fmt.Sprintf("    Airport %d -> Airport %d: ", i+1, i+2); !strings.HasPrefix(line, prefix) {
+			t.Errorf("segment %d is %q rather than from Airport %d to Airport %d", i+1, line, i+1, i+2)
+		}
+	}
+}
+
+// TestMinimumRange flies between airports 889 km apart with stops on the
+// way, the nearest 445 km from each end. Under the fuel model the
+// destination sells no fuel but may still be landed at, while a tank
+// holding little more than the reserve cannot make any hop.
+func TestMinimumRange(t *testing.T) {
+	defer func(savedBurn, savedTank float64) { *burnRate, *tankCapacity = savedBurn, savedTank }(*burnRate, *tankCapacity)
+	defer func(savedPrint, savedMin bool) { *printRoute, *minimumRange = savedPrint, savedMin }(*printRoute, *minimumRange)
+	*printRoute, *minimumRange = false, true
+
+	input := `4 600
+0 0
+8 0
+4 0
+4 2
+1
+1 2 3000
+`
+	cases := []struct {
+		burn, tank float64
+		expected   string
+	}{
+		{0.0, 0.0, `Case 1:
+minimum range 444.709893
+889.420
+`},
+		{1000.0, 2000.0, `Case 1:
+minimum range 444.709893
+889.420
+    refuel 1250.0 kg at Airport 1 for 1250.00
+    refuel 528.4 kg at Airport 3 for 528.44
+    fuel cost: 1778.44
+`},
+		{1000.0, 1200.0, `Case 1:
+impossible
+`},
+	}
+	for _, tc := range cases {
+		*burnRate, *tankCapacity = tc.burn, tc.tank
+		config := loadSettings()
+		config.airportData.fuelPrices = map[string]float64{"Airport 2": -1.0}
+		var out bytes.Buffer
+		run(config, bufio.NewReader(strings.NewReader(input)), &out, "", nil)
+		if out.String() != tc.expected {
+			t.Errorf("burning %.0f kg/h from a %.0f kg tank gives\n%s\nrather than\n%s", tc.burn, tc.tank, out.String(), tc.expected)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	g "graph"
+	"log/slog"
+)
+
+var statistics *bool = flag.Bool("stats", false, "log how much searching each flight took")
+var debug *bool = flag.Bool("debug", false, "log every step of building each network and searching it")
+
+// Observer lets a flight's plan name the observer of its searches.
+func (fs flightState) Observer() g.TraverseObserver[place] {
+	return fs.plan.observer
+}
+
+// observe gives a plan the observer its searches are to be logged by, if
+// any, and returns the stats it keeps, or nil without -stats.
+func observe(plan *flightPlan) *g.TraverseStats[place] {
+	var observers g.Observers[place]
+	var stats *g.TraverseStats[place]
+	if *statistics {
+		stats = &g.TraverseStats[place]{}
+		observers = append(observers, stats)
+	}
+	if *debug {
+		observers = append(observers, g.LogObserver[place]{Logger: slog.Default()})
+	}
+	if len(observers) > 0 {
+		plan.observer = observers
+	}
+	return stats
+}
+
+func logStats(from, to any, stats *g.TraverseStats[place]) {
+	if stats != nil {
+		slog.Info("flight searched", "from", from, "to", to, "stats", stats)
+	}
+}
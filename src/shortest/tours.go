@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"tour"
+)
+
+// Command to plan a closed tour: tour AIRCRAFT AIRPORT AIRPORT ...
+const TOUR_COMMAND = "tour"
+
+// planTour finds the cheapest closed tour from the first of the given
+// airports through all the others and back, flying the given aircraft,
+// which is a plane range or profile name as on a flight line. It prints the
+// tour and the full route flown.
+func (c *caseContext) planTour(args []string) {
+	if len(args) < 2 {
+		panic(TOUR_COMMAND + " needs an aircraft and at least one airport")
+	}
+	profile, planeRange := c.aircraftFor(args[0])
+
+	stops := make([]*placeNode, 0, len(args)-1)
+	for _, name := range args[1:] {
+		node, found := c.find(name)
+		if !found {
+			fmt.Fprintf(c.out, "no airport \"%s\" in this case\n", name)
+			return
+		}
+		stops = append(stops, node)
+	}
+
+	cache := newSegmentCache(c, profile, planeRange)
+	order, total, exact, ok := tour.Tour(cache.costMatrix(stops))
+	if !ok {
+		fmt.Fprintln(c.out, "impossible")
+		return
+	}
+
+	method := "heuristic"
+	if exact {
+		method = "exact"
+	}
+	fmt.Fprintf(c.out, "tour %s (%s)\n", c.formatCost(total), method)
+
+	route := []*placeNode{stops[order[0]]}
+	for i, stop := range order {
+		s, _ := cache.fly(stops[stop], stops[order[(i+1)%len(order)]])
+		fmt.Fprintf(c.out, "    %s -> %s: %s\n", s.from.Record, s.to.Record, c.formatCost(s.cost))
+		if len(s.route) > 0 {
+			route = append(route, s.route[1:]...)
+		}
+	}
+
+	fmt.Fprintln(c.out, "route:")
+	for _, n := range route {
+		fmt.Fprintln(c.out, n.Record.String())
+	}
+}
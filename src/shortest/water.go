@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"sphere"
+)
+
+// Length in km of the stretches of an arc judged as wholly over land or
+// water when measuring how much of it is over water.
+const WATER_STEP_KM = 5.0
+
+var landFileName *string = flag.String("land", "", "name of a file of land areas, in the form of a zone file, for the \"water\" criterion")
+
+// land holds the areas over which flying is not over water, nil unless a
+// land file was given.
+var land []*zone
+
+// overWaterKm returns how far along the arc between two locations is over
+// no land area, to within WATER_STEP_KM.
+func overWaterKm(from, to *sphere.NVector) float64 {
+	distance := from.AngleBetween(to) * EARTH_RADIUS_KM
+	steps := int(math.Ceil(distance / WATER_STEP_KM))
+	water := 0
+	for i := 0; i < steps; i++ {
+		if !onLand(from.Interpolate(to, (float64(i)+0.5)/float64(steps))) {
+			water++
+		}
+	}
+	if steps == 0 {
+		return 0.0
+	}
+	return distance * float64(water) / float64(steps)
+}
+
+func onLand(v *sphere.NVector) bool {
+	for _, area := range land {
+		if area.contains(v) {
+			return true
+		}
+	}
+	return false
+}
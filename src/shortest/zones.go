@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sphere"
+	"strings"
+)
+
+const (
+	ZONE_CIRCLE  = "circle"
+	ZONE_POLYGON = "polygon"
+)
+
+var zoneFileName *string = flag.String("zones", "", "name of restricted airspace file")
+
+// zones holds the restricted areas no edge may cross.
+var zones []*zone
+
+// zoneBlock is an edge out of a node that a zone kept out of the network.
+type zoneBlock struct {
+	other *placeNode
+	zone  *zone
+}
+
+// zone
+
+type zone struct {
+	name        string
+	center      *sphere.NVector // for circles
+	radiusAngle float64
+	polygon     []*sphere.NVector
+}
+
+func (z *zone) String() string {
+	return z.name
+}
+
+func (z *zone) blocks(from, to *sphere.NVector) bool {
+	if z.polygon != nil {
+		return sphere.ArcIntersectsPolygon(from, to, z.polygon)
+	}
+	return sphere.ArcIntersectsCircle(from, to, z.center, z.radiusAngle)
+}
+
+// contains reports whether a location lies within the zone.
+func (z *zone) contains(v *sphere.NVector) bool {
+	if z.polygon != nil {
+		return v.IsInPolygon(z.polygon)
+	}
+	return z.center.AngleBetween(v) <= z.radiusAngle
+}
+
+// readZones reads lines of the form
+//
+//	circle "NAME" lat lon radiusKm
+//	polygon "NAME" lat lon lat lon lat lon ...
+//
+// with coordinates in degrees. Blank lines and lines starting with '#' are
+// ignored.
+func readZones(fileName string) []*zone {
+	in, err := os.Open(fileName)
+	if err != nil {
+		panic("couldn't open zone file \"" + fileName + "\"")
+	}
+	defer func() { in.Close() }()
+
+	result := make([]*zone, 0)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var kind, name string
+		_, err = fmt.Sscanf(line, "%s %q", &kind, &name)
+		if err != nil {
+			panic("couldn't parse zone line \"" + line + "\"")
+		}
+		coords := make([]float64, 0)
+		for _, field := range strings.Fields(line[strings.LastIndex(line, "\"")+1:]) {
+			var f float64
+			if _, err = fmt.Sscanf(field, "%f", &f); err != nil {
+				panic("bad number in zone line \"" + line + "\"")
+			}
+			coords = append(coords, f)
+		}
+
+		z := &zone{name: name}
+		switch {
+		case kind == ZONE_CIRCLE && len(coords) == 3:
+			z.center = sphere.NewNVectorFromLatLongDeg(coords[0], coords[1])
+			z.radiusAngle = coords[2] / EARTH_RADIUS_KM
+		case kind == ZONE_POLYGON && len(coords) >= 6 && len(coords)%2 == 0:
+			z.polygon = make([]*sphere.NVector, 0, len(coords)/2)
+			for i := 0; i < len(coords); i += 2 {
+				z.polygon = append(z.polygon, sphere.NewNVectorFromLatLongDeg(coords[i], coords[i+1]))
+			}
+		default:
+			panic("bad zone line \"" + line + "\"")
+		}
+		result = append(result, z)
+	}
+
+	return result
+}
+
+// blockingZone returns the first zone the arc between two nodes crosses, or
+// nil if the arc is clear.
+func blockingZone(n1, n2 *placeNode) *zone {
+	if len(zones) == 0 {
+		return nil
+	}
+
+	v1 := n1.Record.Location()
+	v2 := n2.Record.Location()
+	for _, z := range zones {
+		if z.blocks(&v1, &v2) {
+			return z
+		}
+	}
+	return nil
+}
+
+// noteBlock records that a zone kept two nodes apart.
+func (nw *network) noteBlock(n1, n2 *placeNode, z *zone) {
+	nw.zoneBlocks[n1] = append(nw.zoneBlocks[n1], zoneBlock{n2, z})
+	nw.zoneBlocks[n2] = append(nw.zoneBlocks[n2], zoneBlock{n1, z})
+}
+
+// forgetBlocks forgets the zones that kept a node removed from others.
+func (nw *network) forgetBlocks(n *placeNode) {
+	for _, block := range nw.zoneBlocks[n] {
+		kept := nw.zoneBlocks[block.other][:0]
+		for _, other := range nw.zoneBlocks[block.other] {
+			if other.other != n {
+				kept = append(kept, other)
+			}
+		}
+		if len(kept) == 0 {
+			delete(nw.zoneBlocks, block.other)
+		} else {
+			nw.zoneBlocks[block.other] = kept
+		}
+	}
+	delete(nw.zoneBlocks, n)
+}
+
+// constrainingZones returns, in the order first met, the zones that kept
+// out of the network an edge from a node on the route that would have made
+// it shorter over the ground: either by cutting across to a later node of
+// the route, or by leading to a node nearer the destination, as the crow
+// flies, than the route still has to go.
+func (nw *network) constrainingZones(route []*placeNode) (result []*zone) {
+	result = make([]*zone, 0)
+	if len(route) == 0 {
+		return
+	}
+
+	// how far along the route each of its nodes is
+	along := make([]float64, len(route))
+	index := make(map[*placeNode]int)
+	for i, n := range route {
+		if i > 0 {
+			along[i] = along[i-1] + groundKm(route[i-1], n)
+		}
+		index[n] = i
+	}
+	total := along[len(route)-1]
+	to := route[len(route)-1]
+
+	seen := make(map[*zone]bool)
+	for i, n := range route {
+		for _, block := range nw.zoneBlocks[n] {
+			if seen[block.zone] {
+				continue
+			}
+			rest := groundKm(block.other, to)
+			if j, onRoute := index[block.other]; onRoute {
+				if j <= i {
+					continue
+				}
+				rest = total - along[j]
+			}
+			if along[i]+groundKm(n, block.other)+rest < total {
+				seen[block.zone] = true
+				result = append(result, block.zone)
+			}
+		}
+	}
+	return
+}
+
+// groundKm returns the great circle distance between two nodes.
+func groundKm(n1, n2 *placeNode) float64 {
+	v1, v2 := n1.Record.Location(), n2.Record.Location()
+	return v1.AngleBetween(&v2) * EARTH_RADIUS_KM
+}
@@ -1,39 +1,89 @@
 package slice_heap
 
-type LessThanFunc func(a, b interface{}) bool
+// LessThanFunc orders the items in a heap. A SliceHeap made with one on
+// interface{} items works as before with container/heap.
+type LessThanFunc[T any] func(a, b T) bool
 
-type SliceHeap struct {
-	collection []interface{}
-	lessThan   LessThanFunc
+type SliceHeap[T any] struct {
+	collection []T
+	lessThan   LessThanFunc[T]
 }
 
-func NewSliceHeap(f LessThanFunc) *SliceHeap {
-	return &SliceHeap{make([]interface{}, 0), f}
+func NewSliceHeap[T any](f LessThanFunc[T]) *SliceHeap[T] {
+	return &SliceHeap[T]{make([]T, 0), f}
 }
 
-func (sh *SliceHeap) Push(item interface{}) {
-	sh.collection = append(sh.collection, item)
+// Push and Pop, with Len, Less and Swap, implement heap.Interface; use the
+// container/heap functions rather than calling them directly, or use
+// PushItem and PopItem.
+
+func (sh *SliceHeap[T]) Push(item interface{}) {
+	sh.collection = append(sh.collection, item.(T))
 }
 
-func (sh *SliceHeap) Pop() (result interface{}) {
+func (sh *SliceHeap[T]) Pop() (result interface{}) {
 	l := len(sh.collection)
 	result = sh.collection[l-1]
 	sh.collection = sh.collection[0 : l-1]
 	return
 }
 
-func (sh *SliceHeap) Len() int {
+func (sh *SliceHeap[T]) Len() int {
 	return len(sh.collection)
 }
 
-func (sh *SliceHeap) Less(i, j int) bool {
+func (sh *SliceHeap[T]) Less(i, j int) bool {
 	return sh.lessThan(sh.collection[i], sh.collection[j])
 }
 
-func (sh *SliceHeap) Swap(i, j int) {
+func (sh *SliceHeap[T]) Swap(i, j int) {
 	sh.collection[i], sh.collection[j] = sh.collection[j], sh.collection[i]
 }
 
-func (sh *SliceHeap) IsEmpty() bool {
+func (sh *SliceHeap[T]) IsEmpty() bool {
 	return 0 == len(sh.collection)
 }
+
+// PushItem adds an item to the heap without converting it to an interface.
+func (sh *SliceHeap[T]) PushItem(item T) {
+	sh.collection = append(sh.collection, item)
+	for i := len(sh.collection) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if !sh.Less(i, parent) {
+			break
+		}
+		sh.Swap(i, parent)
+		i = parent
+	}
+}
+
+// Peek returns the least item in the heap without removing it.
+func (sh *SliceHeap[T]) Peek() T {
+	return sh.collection[0]
+}
+
+// PopItem removes and returns the least item in the heap.
+func (sh *SliceHeap[T]) PopItem() (result T) {
+	l := len(sh.collection) - 1
+	result = sh.collection[0]
+	sh.collection[0] = sh.collection[l]
+	var zero T
+	sh.collection[l] = zero
+	sh.collection = sh.collection[:l]
+
+	for i := 0; ; {
+		least := i
+		if left := 2*i + 1; left < l && sh.Less(left, least) {
+			least = left
+		}
+		if right := 2*i + 2; right < l && sh.Less(right, least) {
+			least = right
+		}
+		if least == i {
+			break
+		}
+		sh.Swap(i, least)
+		i = least
+	}
+	return
+}
@@ -4,6 +4,7 @@ import (
 	"container/heap"
 	"testing"
 	"fmt"
+	"sort"
 )
 
 func IntLessThan(a, b interface{}) bool {
@@ -43,4 +44,21 @@ func TestStringSliceHeap(t *testing.T) {
 			t.Error(fmt.Sprintf("popped %s instead of %s", s, val))
 		}
 	}
-}
\ No newline at end of file
+}
+func TestTypedSliceHeap(t *testing.T) {
+	sh := NewSliceHeap(func(a, b int) bool { return a < b })
+	vals := []int{5, 2, 8, 3, 3, 9, 1, 7, 0, 4, 6}
+	for _, val := range vals {
+		sh.PushItem(val)
+	}
+
+	sort.Ints(vals)
+	for _, val := range vals {
+		if i := sh.PopItem(); i != val {
+			t.Errorf("popped %d instead of %d", i, val)
+		}
+	}
+	if !sh.IsEmpty() {
+		t.Error("heap not empty")
+	}
+}
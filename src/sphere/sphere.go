@@ -8,6 +8,9 @@ import (
 
 type NVector [3]float64
 
+/* Tolerance in radians when deciding whether a point lies on an arc */
+const ARC_EPSILON = 0.000000001
+
 func (v1 *NVector) LessThan(v2 *NVector) bool {
 	if v1[0] < v2[0] {
 		return true
@@ -133,6 +136,53 @@ func (v1 *NVector) ProjectOntoPlane(normal *NVector) *NVector {
 	return v1.Subtract(projectOnto)
 }
 
+/* Returns the point the given fraction of the way along the great circle arc from v1 to v2, with the magnitude of v1 */
+func (v1 *NVector) Interpolate(v2 *NVector, fraction float64) *NVector {
+	angle := v1.AngleBetween(v2)
+	if angle == 0 {
+		return v1.ScaleBy(1.0)
+	}
+	u1 := v1.Normalize()
+	s := math.Sin(angle)
+	if s < ARC_EPSILON {
+		/* antipodal, so follow the great circle ArcNormal picks */
+		track := u1.TrackDirection(v1.ArcNormal(v2))
+		r := u1.ScaleBy(math.Cos(fraction * angle)).Add(track.ScaleBy(math.Sin(fraction * angle)))
+		return r.ScaleTo(v1.Magnitude())
+	}
+	u2 := v2.Normalize()
+	r := u1.ScaleBy(math.Sin((1-fraction)*angle) / s).Add(u2.ScaleBy(math.Sin(fraction*angle) / s))
+	return r.ScaleTo(v1.Magnitude())
+}
+
+/* Returns unit vectors pointing east and north at v; at the poles east is taken along the y axis */
+func (v *NVector) EastNorth() (east, north *NVector) {
+	up := v.Normalize()
+	east = NewNVector(0, 0, 1).CrossProduct(up)
+	if east.Magnitude() == 0 {
+		east = NewNVector(0, 1, 0)
+	} else {
+		east = east.Normalize()
+	}
+	north = up.CrossProduct(east)
+	return
+}
+
+/* Returns the unit normal of the great circle along which v1 heads toward v2; antipodal points lie on every great circle through them, of which the one heading north from v1 (along the y axis at the poles) is taken */
+func (v1 *NVector) ArcNormal(v2 *NVector) *NVector {
+	normal := v1.CrossProduct(v2)
+	if normal.Magnitude() > ARC_EPSILON*v1.Magnitude()*v2.Magnitude() {
+		return normal.Normalize()
+	}
+	east, _ := v1.EastNorth()
+	return east.ScaleBy(-1)
+}
+
+/* Returns the unit direction of travel at point v along the great circle with the given normal */
+func (v *NVector) TrackDirection(normal *NVector) *NVector {
+	return normal.CrossProduct(v).Normalize()
+}
+
 /* Assumes vb, v1, and v2 are all on the same plane; if not the results will not be accurate */
 func (vb *NVector) IsBetween(v1, v2 *NVector) bool {
 	big := v1.AngleBetween(v2)
@@ -177,6 +227,116 @@ func (start *NVector) IsWithinEpsilon(extreme1, extreme2, between *NVector, epsi
 	return toBetweenPlane.IsBetweenEpsilon(toExtreme1Plane, toExtreme2Plane, epsilon)
 }
 
+/* Returns the angle from v to the nearest point on the minor great circle arc from a to b */
+func (v *NVector) AngleToArc(a, b *NVector) float64 {
+	normal := a.CrossProduct(b)
+	if normal.Magnitude() == 0 {
+		return math.Min(v.AngleBetween(a), v.AngleBetween(b))
+	}
+	normal = normal.Normalize()
+	onCircle := v.ProjectOntoPlane(normal)
+	if onCircle.Magnitude() != 0 && onCircle.IsBetweenEpsilon(a, b, ARC_EPSILON) {
+		return math.Abs(math.Pi/2 - v.AngleBetween(normal))
+	}
+	return math.Min(v.AngleBetween(a), v.AngleBetween(b))
+}
+
+/* Returns whether the minor great circle arc from a to b comes within the given angle of center */
+func ArcIntersectsCircle(a, b, center *NVector, radiusAngle float64) bool {
+	return center.AngleToArc(a, b) <= radiusAngle
+}
+
+/* Returns whether the minor great circle arcs a1-b1 and a2-b2 cross */
+func ArcsIntersect(a1, b1, a2, b2 *NVector) bool {
+	line := a1.CrossProduct(b1).CrossProduct(a2.CrossProduct(b2))
+	if line.Magnitude() < ARC_EPSILON {
+		/* on the same great circle; they cross if either contains an end of the other */
+		return a1.IsBetweenEpsilon(a2, b2, ARC_EPSILON) || b1.IsBetweenEpsilon(a2, b2, ARC_EPSILON) ||
+			a2.IsBetweenEpsilon(a1, b1, ARC_EPSILON) || b2.IsBetweenEpsilon(a1, b1, ARC_EPSILON)
+	}
+	line = line.Normalize()
+	for _, p := range []*NVector{line, line.ScaleBy(-1.0)} {
+		if p.IsBetweenEpsilon(a1, b1, ARC_EPSILON) && p.IsBetweenEpsilon(a2, b2, ARC_EPSILON) {
+			return true
+		}
+	}
+	return false
+}
+
+/* Returns whether v lies inside the spherical polygon with the given vertices, by summing the angles the edges subtend at v */
+func (v *NVector) IsInPolygon(polygon []*NVector) bool {
+	p := v.Normalize()
+	winding := 0.0
+	for i, v1 := range polygon {
+		v2 := polygon[(i+1)%len(polygon)]
+		y := v1.CrossProduct(v2).DotProduct(p)
+		x := v1.DotProduct(v2) - v1.DotProduct(p)*v2.DotProduct(p)
+		winding += math.Atan2(y, x)
+	}
+	return math.Abs(winding) > math.Pi
+}
+
+/* Returns whether the minor great circle arc from a to b enters the spherical polygon with the given vertices */
+func ArcIntersectsPolygon(a, b *NVector, polygon []*NVector) bool {
+	if a.IsInPolygon(polygon) || b.IsInPolygon(polygon) {
+		return true
+	}
+	for i, v1 := range polygon {
+		if ArcsIntersect(a, b, v1, polygon[(i+1)%len(polygon)]) {
+			return true
+		}
+	}
+	return false
+}
+
+/* Returns, to within tolerance, the greatest angle from any point on the minor arc a-b to the nearest of points, and a point on the arc where it occurs */
+func MaxGapAlongArc(a, b *NVector, points []*NVector, tolerance float64) (gap float64, at *NVector) {
+	if len(points) == 0 {
+		return math.Inf(1), a
+	}
+	nearest := func(v *NVector) (result float64) {
+		result = math.Inf(1)
+		for _, p := range points {
+			result = math.Min(result, v.AngleBetween(p))
+		}
+		return
+	}
+
+	/* the distance to the nearest point changes no faster than we move, so no point between two samples can beat the larger of them by more than half their separation */
+	type sample struct {
+		fraction, gap float64
+		v             *NVector
+	}
+	arc := a.AngleBetween(b)
+	first := sample{0, nearest(a), a}
+	last := sample{1, nearest(b), b}
+	gap, at = first.gap, a
+	if last.gap > gap {
+		gap, at = last.gap, b
+	}
+
+	pending := [][2]sample{{first, last}}
+	for len(pending) > 0 {
+		interval := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		lo, hi := interval[0], interval[1]
+		bound := math.Max(lo.gap, hi.gap) + arc*(hi.fraction-lo.fraction)/2
+		if bound <= gap+tolerance {
+			continue
+		}
+
+		fraction := (lo.fraction + hi.fraction) / 2
+		v := a.Interpolate(b, fraction)
+		mid := sample{fraction, nearest(v), v}
+		if mid.gap > gap {
+			gap, at = mid.gap, v
+		}
+		pending = append(pending, [2]sample{lo, mid}, [2]sample{mid, hi})
+	}
+
+	return
+}
+
 type Transformation [3][3]float64
 
 func (v *NVector) TransformationMatrix() (result *Transformation) {
@@ -58,7 +58,7 @@ func TestCircles(t *testing.T) {
 		for _, point := range points {
 			d := place.AngleBetween(point) * earthRadiusKm
 			if math.Abs(radius-d) > floatEpsilon {
-				t.Errorf("distance is %f rather than %d", d, radius)
+				t.Errorf("distance is %f rather than %f", d, radius)
 			}
 		}
 	}
@@ -149,3 +149,138 @@ func TestIsBetween(t *testing.T) {
 		t.Errorf("opposite test expected success")
 	}
 }
+
+func TestInterpolate(t *testing.T) {
+	bna := NewNVectorFromLatLongDeg(36.12, -86.67)
+	lax := NewNVectorFromLatLongDeg(33.94, -118.40)
+	whole := bna.AngleBetween(lax)
+
+	for _, fraction := range []float64{0.0, 0.25, 0.5, 0.9, 1.0} {
+		p := bna.Interpolate(lax, fraction)
+		if math.Abs(p.Magnitude()-1.0) > floatEpsilon {
+			t.Errorf("interpolated point at %f is off the sphere", fraction)
+		}
+		if math.Abs(bna.AngleBetween(p)-fraction*whole) > floatEpsilon {
+			t.Errorf("interpolated point at %f is the wrong distance along", fraction)
+		}
+		if math.Abs(bna.AngleBetween(p)+p.AngleBetween(lax)-whole) > floatEpsilon {
+			t.Errorf("interpolated point at %f is off the great circle", fraction)
+		}
+	}
+}
+
+func TestEastNorthTrack(t *testing.T) {
+	equator := NewNVectorFromLatLongDeg(0.0, 0.0)
+	east, north := equator.EastNorth()
+	if math.Abs(east[1]-1.0) > floatEpsilon || math.Abs(north[2]-1.0) > floatEpsilon {
+		t.Errorf("east %s or north %s is wrong at the equator", east, north)
+	}
+
+	eastward := NewNVectorFromLatLongDeg(0.0, 10.0)
+	track := equator.TrackDirection(equator.CrossProduct(eastward).Normalize())
+	if math.Abs(track.DotProduct(east)-1.0) > floatEpsilon {
+		t.Errorf("track %s along the equator should point east", track)
+	}
+}
+
+func TestArcIntersections(t *testing.T) {
+	seattle := NewNVectorFromLatLongDeg(47.609722, -122.333056)
+	savannah := NewNVectorFromLatLongDeg(32.081111, -81.091111)
+	stpaul := NewNVectorFromLatLongDeg(44.9441, -93.0852)
+	sanAntonio := NewNVectorFromLatLongDeg(29.416667, -98.5)
+	saline := NewNVectorFromLatLongDeg(42.170833, -83.779722)
+	syracuse := NewNVectorFromLatLongDeg(43.046944, -76.144167)
+
+	if !ArcsIntersect(seattle, savannah, stpaul, sanAntonio) {
+		t.Errorf("Seattle-Savannah should cross St. Paul-San Antonio")
+	}
+	if ArcsIntersect(seattle, stpaul, saline, syracuse) {
+		t.Errorf("Seattle-St. Paul should not cross Saline-Syracuse")
+	}
+
+	/* a rough box around Kansas, which the Seattle-Savannah arc crosses */
+	kansas := []*NVector{NewNVectorFromLatLongDeg(37, -102), NewNVectorFromLatLongDeg(37, -94.6),
+		NewNVectorFromLatLongDeg(40, -94.6), NewNVectorFromLatLongDeg(40, -102)}
+	wichita := NewNVectorFromLatLongDeg(37.688889, -97.336111)
+	if !wichita.IsInPolygon(kansas) || stpaul.IsInPolygon(kansas) {
+		t.Errorf("point in polygon test failed")
+	}
+	if !ArcIntersectsPolygon(seattle, savannah, kansas) {
+		t.Errorf("Seattle-Savannah should cross Kansas")
+	}
+	if ArcIntersectsPolygon(saline, syracuse, kansas) {
+		t.Errorf("Saline-Syracuse should not cross Kansas")
+	}
+
+	/* a point on the arc is at zero distance, and one off to the side is not */
+	midpoint := seattle.Interpolate(savannah, 0.5)
+	if d := midpoint.AngleToArc(seattle, savannah); d > floatEpsilon {
+		t.Errorf("midpoint is %f from its own arc", d)
+	}
+	d := stpaul.AngleToArc(seattle, savannah) * earthRadiusKm
+	if !ArcIntersectsCircle(seattle, savannah, stpaul, (d+1)/earthRadiusKm) ||
+		ArcIntersectsCircle(seattle, savannah, stpaul, (d-1)/earthRadiusKm) {
+		t.Errorf("circle test inconsistent with distance of %f km", d)
+	}
+	if d := seattle.AngleToArc(stpaul, savannah); math.Abs(d-seattle.AngleBetween(stpaul)) > floatEpsilon {
+		t.Errorf("distance beyond the end of an arc should be to the end point")
+	}
+}
+
+func TestInterpolateAntipodal(t *testing.T) {
+	/* no single arc joins opposite points, so the one heading north is taken */
+	west := NewNVectorFromLatLongDeg(0, 0)
+	east := NewNVectorFromLatLongDeg(0, 180)
+	for _, fraction := range []float64{0.0, 0.25, 0.5, 1.0} {
+		p := west.Interpolate(east, fraction)
+		if math.IsNaN(p.Magnitude()) || math.Abs(p.Magnitude()-1.0) > floatEpsilon {
+			t.Errorf("interpolated point at %f is off the sphere", fraction)
+		}
+		if math.Abs(west.AngleBetween(p)-fraction*math.Pi) > floatEpsilon {
+			t.Errorf("interpolated point at %f is the wrong distance along", fraction)
+		}
+	}
+	if p := west.Interpolate(east, 0.5); math.Abs(p[2]-1.0) > floatEpsilon {
+		t.Errorf("antipodal midpoint %s is not the north pole", p)
+	}
+	if track := west.TrackDirection(west.ArcNormal(east)); math.Abs(track[2]-1.0) > floatEpsilon {
+		t.Errorf("antipodal arc heads %s rather than north", track)
+	}
+}
+
+func TestMaxGapAlongArc(t *testing.T) {
+	/* two points on the equator; the worst spot between them is half way */
+	west := NewNVectorFromLatLongDeg(0, -10)
+	east := NewNVectorFromLatLongDeg(0, 10)
+	tolerance := 0.01 / earthRadiusKm
+	gap, at := MaxGapAlongArc(west, east, []*NVector{west, east}, tolerance)
+	if math.Abs(gap-west.AngleBetween(east)/2) > tolerance {
+		t.Errorf("gap between ends is %f", gap)
+	}
+	if lat, lon := at.ToLatLonDegrees(); math.Abs(lat) > 0.01 || math.Abs(lon) > 0.01 {
+		t.Errorf("gap found at (%f, %f) rather than the midpoint", lat, lon)
+	}
+
+	/* a point above the middle of the arc shrinks the gap */
+	north := NewNVectorFromLatLongDeg(3, 0)
+	gap2, _ := MaxGapAlongArc(west, east, []*NVector{west, east, north}, tolerance)
+	if gap2 >= gap {
+		t.Errorf("extra point did not shrink the gap")
+	}
+
+	/* compare against dense sampling, which can miss the worst spot by half its spacing */
+	spacing := west.AngleBetween(east) / 10000
+	dense := 0.0
+	points := []*NVector{west, east, north}
+	for i := 0; i <= 10000; i++ {
+		p := west.Interpolate(east, float64(i)/10000)
+		nearest := math.Inf(1)
+		for _, q := range points {
+			nearest = math.Min(nearest, p.AngleBetween(q))
+		}
+		dense = math.Max(dense, nearest)
+	}
+	if gap2 < dense-tolerance || gap2 > dense+spacing/2+tolerance {
+		t.Errorf("gap of %f differs from sampled %f", gap2, dense)
+	}
+}
@@ -0,0 +1,271 @@
+package tour
+
+import (
+	"math"
+)
+
+// Largest number of stops ExactPath will take on; its time grows as
+// 2^n * n^2.
+const MAX_EXACT_STOPS = 16
+
+// Cost of travelling between a pair of stops with no route between them.
+var Infeasible = math.Inf(1)
+
+// ExactPath returns the cheapest order in which to visit every stop, using
+// the Held-Karp dynamic program. cost[i][j] is the cost from stop i to stop
+// j. The order begins with start and, unless end is negative, finishes with
+// end. It is not ok when there are too many stops or no feasible order.
+func ExactPath(cost [][]float64, start, end int) (order []int, total float64, ok bool) {
+	n := len(cost)
+	if n > MAX_EXACT_STOPS || start < 0 || start >= n || end >= n || end == start {
+		return nil, 0, false
+	}
+	if n == 1 {
+		return []int{start}, 0, true
+	}
+
+	// best[mask][j] is the cheapest cost of leaving start, visiting exactly
+	// the stops in mask and finishing at j, which is in mask; start itself
+	// is never in mask.
+	full := (1 << uint(n)) - 1 - (1 << uint(start))
+	best := make([][]float64, full+1)
+	prev := make([][]int, full+1)
+	for mask := range best {
+		best[mask] = make([]float64, n)
+		prev[mask] = make([]int, n)
+		for j := range best[mask] {
+			best[mask][j] = Infeasible
+			prev[mask][j] = -1
+		}
+	}
+	for j := 0; j < n; j++ {
+		if j != start {
+			best[1<<uint(j)][j] = cost[start][j]
+		}
+	}
+
+	for mask := 1; mask <= full; mask++ {
+		if mask&(1<<uint(start)) != 0 {
+			continue
+		}
+		for j := 0; j < n; j++ {
+			if mask&(1<<uint(j)) == 0 || math.IsInf(best[mask][j], 1) {
+				continue
+			}
+			// the end stop may only be visited last
+			if j == end && mask != full {
+				continue
+			}
+			for k := 0; k < n; k++ {
+				if mask&(1<<uint(k)) != 0 || k == start {
+					continue
+				}
+				next := mask | (1 << uint(k))
+				if c := best[mask][j] + cost[j][k]; c < best[next][k] {
+					best[next][k] = c
+					prev[next][k] = j
+				}
+			}
+		}
+	}
+
+	last := end
+	if last < 0 {
+		for j := 0; j < n; j++ {
+			if j != start && (last < 0 || best[full][j] < best[full][last]) {
+				last = j
+			}
+		}
+	}
+	if last == start || math.IsInf(best[full][last], 1) {
+		return nil, 0, false
+	}
+
+	total = best[full][last]
+	order = make([]int, n)
+	for i, mask, j := n-1, full, last; i > 0; i-- {
+		order[i] = j
+		mask, j = mask&^(1<<uint(j)), prev[mask][j]
+	}
+	order[0] = start
+
+	return order, total, true
+}
+
+// ExactTour returns the cheapest closed tour through every stop, beginning
+// and implicitly ending at stop 0. Since the return to stop 0 counts as a
+// stop of its own, it takes on no more than MAX_EXACT_STOPS-1 stops.
+func ExactTour(cost [][]float64) (order []int, total float64, ok bool) {
+	n := len(cost)
+	if n == 0 {
+		return nil, 0, false
+	}
+
+	// a path from stop 0 to a copy of it visits everything in between
+	augmented := make([][]float64, n+1)
+	for i := 0; i <= n; i++ {
+		augmented[i] = make([]float64, n+1)
+		for j := 0; j <= n; j++ {
+			augmented[i][j] = cost[i%n][j%n]
+		}
+	}
+	order, total, ok = ExactPath(augmented, 0, n)
+	if !ok {
+		return nil, 0, false
+	}
+	return order[:n], total, true
+}
+
+// TourCost returns the cost of visiting the stops in order and returning to
+// the first.
+func TourCost(cost [][]float64, order []int) (total float64) {
+	for i, stop := range order {
+		total += cost[stop][order[(i+1)%len(order)]]
+	}
+	return
+}
+
+// HeuristicTour builds a closed tour beginning at stop 0 by nearest
+// neighbour and improves it with 2-opt and Or-opt moves until neither
+// helps. Costs need not be symmetric. It is not ok if no feasible tour was
+// found, which does not prove there is none.
+func HeuristicTour(cost [][]float64) (order []int, total float64, ok bool) {
+	order = nearestNeighbourTour(cost)
+	total = TourCost(cost, order)
+
+	for improved := true; improved; {
+		improved = false
+		if candidate, candidateTotal := twoOpt(cost, order, total); candidateTotal < total {
+			order, total, improved = candidate, candidateTotal, true
+		}
+		if candidate, candidateTotal := orOpt(cost, order, total); candidateTotal < total {
+			order, total, improved = candidate, candidateTotal, true
+		}
+	}
+
+	return order, total, !math.IsInf(total, 1)
+}
+
+// Tour returns an exact tour when there are few enough stops and a
+// heuristic one otherwise, noting which.
+func Tour(cost [][]float64) (order []int, total float64, exact, ok bool) {
+	if len(cost) < MAX_EXACT_STOPS {
+		order, total, ok = ExactTour(cost)
+		return order, total, true, ok
+	}
+	order, total, ok = HeuristicTour(cost)
+	return order, total, false, ok
+}
+
+// HeuristicPath orders the stops as ExactPath does, but by HeuristicTour,
+// so it takes on any number of stops. It is not ok if no feasible order was
+// found, which does not prove there is none.
+func HeuristicPath(cost [][]float64, start, end int) (order []int, total float64, ok bool) {
+	n := len(cost)
+	if start < 0 || start >= n || end >= n || end == start {
+		return nil, 0, false
+	}
+
+	// a tour that begins at start, renumbered as stop 0, and may only
+	// return to it, for nothing, from end, or from anywhere without one
+	stop := func(i int) int { return (start + i) % n }
+	looped := make([][]float64, n)
+	for i := range looped {
+		looped[i] = make([]float64, n)
+		for j := range looped[i] {
+			switch {
+			case j != 0:
+				looped[i][j] = cost[stop(i)][stop(j)]
+			case end < 0 || stop(i) == end:
+				looped[i][j] = 0
+			default:
+				looped[i][j] = Infeasible
+			}
+		}
+	}
+
+	order, total, ok = HeuristicTour(looped)
+	if !ok {
+		return nil, 0, false
+	}
+	for i := range order {
+		order[i] = stop(order[i])
+	}
+	return order, total, true
+}
+
+// Path returns an exact path when there are few enough stops and a
+// heuristic one otherwise, noting which.
+func Path(cost [][]float64, start, end int) (order []int, total float64, exact, ok bool) {
+	if len(cost) <= MAX_EXACT_STOPS {
+		order, total, ok = ExactPath(cost, start, end)
+		return order, total, true, ok
+	}
+	order, total, ok = HeuristicPath(cost, start, end)
+	return order, total, false, ok
+}
+
+func nearestNeighbourTour(cost [][]float64) []int {
+	n := len(cost)
+	order := make([]int, 0, n)
+	visited := make([]bool, n)
+	for current := 0; len(order) < n; {
+		order = append(order, current)
+		visited[current] = true
+		next := -1
+		for j := 0; j < n; j++ {
+			if !visited[j] && (next < 0 || cost[current][j] < cost[current][next]) {
+				next = j
+			}
+		}
+		current = next
+	}
+	return order
+}
+
+// twoOpt returns the best tour found by reversing a stretch of stops, or
+// the given tour if none is better.
+func twoOpt(cost [][]float64, order []int, total float64) ([]int, float64) {
+	best, bestTotal := order, total
+	candidate := make([]int, len(order))
+	for i := 1; i < len(order)-1; i++ {
+		for j := i + 1; j < len(order); j++ {
+			copy(candidate, order)
+			for a, b := i, j; a < b; a, b = a+1, b-1 {
+				candidate[a], candidate[b] = candidate[b], candidate[a]
+			}
+			if c := TourCost(cost, candidate); c < bestTotal {
+				best, bestTotal = append([]int(nil), candidate...), c
+			}
+		}
+	}
+	return best, bestTotal
+}
+
+// orOpt returns the best tour found by moving a run of up to three stops
+// elsewhere in the tour, or the given tour if none is better.
+func orOpt(cost [][]float64, order []int, total float64) ([]int, float64) {
+	best, bestTotal := order, total
+	n := len(order)
+	for length := 1; length <= 3 && length < n-1; length++ {
+		for i := 1; i+length <= n; i++ {
+			run := order[i : i+length]
+			rest := make([]int, 0, n-length)
+			rest = append(rest, order[:i]...)
+			rest = append(rest, order[i+length:]...)
+			for k := 1; k <= len(rest); k++ {
+				if k == i {
+					continue
+				}
+				candidate := make([]int, 0, n)
+				candidate = append(candidate, rest[:k]...)
+				candidate = append(candidate, run...)
+				candidate = append(candidate, rest[k:]...)
+				if c := TourCost(cost, candidate); c < bestTotal {
+					best, bestTotal = candidate, c
+				}
+			}
+		}
+	}
+	return best, bestTotal
+}
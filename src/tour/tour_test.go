@@ -0,0 +1,242 @@
+package tour
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+const floatEpsilon = 0.000000001
+
+func randomCosts(r *rand.Rand, n int) [][]float64 {
+	cost := make([][]float64, n)
+	for i := range cost {
+		cost[i] = make([]float64, n)
+		for j := range cost[i] {
+			if i != j {
+				cost[i][j] = float64(r.Intn(100) + 1)
+			}
+		}
+	}
+	return cost
+}
+
+func pathCost(cost [][]float64, order []int) (total float64) {
+	for i := 1; i < len(order); i++ {
+		total += cost[order[i-1]][order[i]]
+	}
+	return
+}
+
+// bruteForcePath tries every ordering of the stops between start and end.
+func bruteForcePath(cost [][]float64, start, end int) (best float64) {
+	best = math.Inf(1)
+	middle := make([]int, 0)
+	for i := range cost {
+		if i != start && i != end {
+			middle = append(middle, i)
+		}
+	}
+
+	var permute func(k int)
+	permute = func(k int) {
+		if k == len(middle) {
+			order := append([]int{start}, middle...)
+			if end >= 0 {
+				order = append(order, end)
+			}
+			best = math.Min(best, pathCost(cost, order))
+			return
+		}
+		for i := k; i < len(middle); i++ {
+			middle[k], middle[i] = middle[i], middle[k]
+			permute(k + 1)
+			middle[k], middle[i] = middle[i], middle[k]
+		}
+	}
+	permute(0)
+	return
+}
+
+func TestExactPath(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 50; trial++ {
+		n := 2 + r.Intn(6)
+		cost := randomCosts(r, n)
+		start := r.Intn(n)
+		end := r.Intn(n)
+		if end == start {
+			end = -1
+		}
+
+		order, total, ok := ExactPath(cost, start, end)
+		if !ok {
+			t.Fatalf("no path found among %d stops", n)
+		}
+		if len(order) != n || order[0] != start || (end >= 0 && order[n-1] != end) {
+			t.Errorf("bad order %v from %d to %d", order, start, end)
+		}
+		if math.Abs(pathCost(cost, order)-total) > floatEpsilon {
+			t.Errorf("order %v costs %f, not the reported %f", order, pathCost(cost, order), total)
+		}
+		if expected := bruteForcePath(cost, start, end); math.Abs(total-expected) > floatEpsilon {
+			t.Errorf("path costs %f rather than %f", total, expected)
+		}
+	}
+}
+
+func TestInfeasiblePath(t *testing.T) {
+	cost := [][]float64{
+		{0, 1, Infeasible},
+		{Infeasible, 0, Infeasible},
+		{1, 1, 0},
+	}
+	if _, _, ok := ExactPath(cost, 0, 2); ok {
+		t.Errorf("path should be infeasible")
+	}
+	if order, total, ok := ExactPath(cost, 2, 1); !ok || total != 2 || order[1] != 0 {
+		t.Errorf("expected path 2, 0, 1 rather than %v at %f", order, total)
+	}
+}
+
+// bruteForceTour tries every closed tour beginning at stop 0.
+func bruteForceTour(cost [][]float64) float64 {
+	n := len(cost)
+	augmented := make([][]float64, n+1)
+	for i := 0; i <= n; i++ {
+		augmented[i] = make([]float64, n+1)
+		for j := 0; j <= n; j++ {
+			augmented[i][j] = cost[i%n][j%n]
+		}
+	}
+	return bruteForcePath(augmented, 0, n)
+}
+
+func TestExactTour(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for trial := 0; trial < 50; trial++ {
+		cost := randomCosts(r, 2+r.Intn(6))
+		order, total, ok := ExactTour(cost)
+		if !ok || order[0] != 0 || len(order) != len(cost) {
+			t.Fatalf("bad tour %v", order)
+		}
+		if math.Abs(TourCost(cost, order)-total) > floatEpsilon {
+			t.Errorf("tour %v costs %f, not the reported %f", order, TourCost(cost, order), total)
+		}
+		if expected := bruteForceTour(cost); math.Abs(total-expected) > floatEpsilon {
+			t.Errorf("tour costs %f rather than %f", total, expected)
+		}
+	}
+}
+
+func TestHeuristicTour(t *testing.T) {
+	// points on a circle visited out of order; the best tour goes round it
+	r := rand.New(rand.NewSource(3))
+	n := 30
+	angles := r.Perm(n)
+	cost := make([][]float64, n)
+	for i := range cost {
+		cost[i] = make([]float64, n)
+		for j := range cost[i] {
+			a := 2 * math.Pi * float64(angles[i]) / float64(n)
+			b := 2 * math.Pi * float64(angles[j]) / float64(n)
+			cost[i][j] = math.Hypot(math.Cos(a)-math.Cos(b), math.Sin(a)-math.Sin(b))
+		}
+	}
+
+	order, total, ok := HeuristicTour(cost)
+	if !ok || order[0] != 0 || len(order) != n {
+		t.Fatalf("bad tour %v", order)
+	}
+	seen := make(map[int]bool)
+	for _, stop := range order {
+		seen[stop] = true
+	}
+	if len(seen) != n {
+		t.Errorf("tour %v misses stops", order)
+	}
+	if perimeter := 2 * float64(n) * math.Sin(math.Pi/float64(n)); math.Abs(total-perimeter) > 0.000001 {
+		t.Errorf("tour costs %f rather than %f", total, perimeter)
+	}
+
+	// on small problems the heuristic should never beat the exact answer
+	for trial := 0; trial < 20; trial++ {
+		small := randomCosts(r, 3+r.Intn(5))
+		_, exact, _ := ExactTour(small)
+		if _, heuristic, _ := HeuristicTour(small); heuristic < exact-floatEpsilon {
+			t.Errorf("heuristic tour %f beats exact %f", heuristic, exact)
+		}
+	}
+}
+
+// TestTourBoundary checks that Tour is exact up to the most stops
+// ExactTour takes, and falls back on the heuristic beyond, finding a tour
+// either way.
+func TestTourBoundary(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	for _, n := range []int{MAX_EXACT_STOPS - 1, MAX_EXACT_STOPS, MAX_EXACT_STOPS + 1} {
+		cost := randomCosts(r, n)
+		order, total, exact, ok := Tour(cost)
+		if !ok || len(order) != n || order[0] != 0 {
+			t.Errorf("no tour of %d stops; got %v (ok %t)", n, order, ok)
+			continue
+		}
+		if exact != (n < MAX_EXACT_STOPS) {
+			t.Errorf("tour of %d stops exact %t", n, exact)
+		}
+		if math.Abs(TourCost(cost, order)-total) > floatEpsilon {
+			t.Errorf("tour %v costs %f, not the reported %f", order, TourCost(cost, order), total)
+		}
+	}
+}
+
+func TestHeuristicPath(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	for trial := 0; trial < 50; trial++ {
+		n := 2 + r.Intn(6)
+		cost := randomCosts(r, n)
+		start := r.Intn(n)
+		end := r.Intn(n)
+		if end == start {
+			end = -1
+		}
+
+		order, total, ok := HeuristicPath(cost, start, end)
+		if !ok {
+			t.Fatalf("no path found among %d stops", n)
+		}
+		seen := make(map[int]bool)
+		for _, stop := range order {
+			seen[stop] = true
+		}
+		if len(order) != n || len(seen) != n || order[0] != start || (end >= 0 && order[n-1] != end) {
+			t.Errorf("bad order %v from %d to %d", order, start, end)
+		}
+		if math.Abs(pathCost(cost, order)-total) > floatEpsilon {
+			t.Errorf("order %v costs %f, not the reported %f", order, pathCost(cost, order), total)
+		}
+		if expected := bruteForcePath(cost, start, end); total < expected-floatEpsilon {
+			t.Errorf("heuristic path %f beats exact %f", total, expected)
+		}
+	}
+}
+
+// TestPathBoundary checks that Path is exact up to MAX_EXACT_STOPS and
+// falls back on the heuristic beyond, finding a path either way.
+func TestPathBoundary(t *testing.T) {
+	r := rand.New(rand.NewSource(6))
+	for _, n := range []int{MAX_EXACT_STOPS, MAX_EXACT_STOPS + 1} {
+		cost := randomCosts(r, n)
+		order, total, exact, ok := Path(cost, 1, 0)
+		if !ok || len(order) != n || order[0] != 1 || order[n-1] != 0 {
+			t.Errorf("no path of %d stops; got %v (ok %t)", n, order, ok)
+			continue
+		}
+		if exact != (n <= MAX_EXACT_STOPS) {
+			t.Errorf("path of %d stops exact %t", n, exact)
+		}
+		if math.Abs(pathCost(cost, order)-total) > floatEpsilon {
+			t.Errorf("path %v costs %f, not the reported %f", order, pathCost(cost, order), total)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package wind
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sphere"
+	"strings"
+)
+
+// Spacing in km between samples when integrating along a great circle arc.
+const SAMPLE_SPACING_KM = 50.0
+
+// Grid holds wind components (u toward east, v toward north, both in km/h)
+// on a regular latitude/longitude grid, in degrees.
+type Grid struct {
+	lats, lons []float64
+	globalLons bool        // the longitudes go all the way round
+	u, v       [][]float64 // indexed [lat][lon]
+}
+
+// ReadGrid reads comma separated lines of "lat,lon,u,v". Blank lines and
+// lines starting with '#' are ignored. Every latitude must appear with
+// every longitude.
+func ReadGrid(in io.Reader) (*Grid, error) {
+	type sample struct{ lat, lon, u, v float64 }
+	samples := make([]sample, 0)
+	latSet := make(map[float64]bool)
+	lonSet := make(map[float64]bool)
+
+	scanner := bufio.NewScanner(in)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var s sample
+		_, err := fmt.Sscanf(strings.Replace(line, ",", " ", -1), "%f %f %f %f", &s.lat, &s.lon, &s.u, &s.v)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+		samples = append(samples, s)
+		latSet[s.lat] = true
+		lonSet[s.lon] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, errors.New("empty wind grid")
+	}
+
+	lons := sortedKeys(lonSet)
+	grid := &Grid{sortedKeys(latSet), lons, goesRound(lons), nil, nil}
+	if len(grid.lats)*len(grid.lons) != len(samples) {
+		return nil, errors.New("wind grid is not a complete lat/lon grid")
+	}
+
+	grid.u = make([][]float64, len(grid.lats))
+	grid.v = make([][]float64, len(grid.lats))
+	for i := range grid.lats {
+		grid.u[i] = make([]float64, len(grid.lons))
+		grid.v[i] = make([]float64, len(grid.lons))
+	}
+	for _, s := range samples {
+		i := sort.SearchFloat64s(grid.lats, s.lat)
+		j := sort.SearchFloat64s(grid.lons, s.lon)
+		grid.u[i][j] = s.u
+		grid.v[i][j] = s.v
+	}
+
+	return grid, nil
+}
+
+func sortedKeys(set map[float64]bool) (keys []float64) {
+	keys = make([]float64, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Float64s(keys)
+	return
+}
+
+// goesRound reports whether longitudes cover the globe, leaving no wider gap
+// from the last round to the first than between any two neighbours.
+func goesRound(lons []float64) bool {
+	if len(lons) < 2 {
+		return false
+	}
+	widest := 0.0
+	for i := 1; i < len(lons); i++ {
+		widest = math.Max(widest, lons[i]-lons[i-1])
+	}
+	return lons[0]+360-lons[len(lons)-1] <= widest
+}
+
+// bracket returns the indexes surrounding x and how far x lies between
+// them. Values outside the axis are clamped unless wrap is set, in which
+// case the axis is treated as circular over 360 degrees.
+func bracket(axis []float64, x float64, wrap bool) (lo, hi int, fraction float64) {
+	n := len(axis)
+	if n == 1 {
+		return 0, 0, 0
+	}
+	if wrap {
+		for x < axis[0] {
+			x += 360
+		}
+		for x >= axis[0]+360 {
+			x -= 360
+		}
+		if x > axis[n-1] {
+			span := axis[0] + 360 - axis[n-1]
+			return n - 1, 0, (x - axis[n-1]) / span
+		}
+	} else if x <= axis[0] {
+		return 0, 0, 0
+	} else if x >= axis[n-1] {
+		return n - 1, n - 1, 0
+	}
+
+	hi = sort.SearchFloat64s(axis, x)
+	if axis[hi] == x {
+		return hi, hi, 0
+	}
+	lo = hi - 1
+	return lo, hi, (x - axis[lo]) / (axis[hi] - axis[lo])
+}
+
+// At returns the wind at the given position by bilinear interpolation.
+// Beyond the edges of a regional grid, the wind at the nearest edge is
+// taken, whichever way round its longitudes are written.
+func (grid *Grid) At(lat, lon float64) (u, v float64) {
+	if !grid.globalLons {
+		middle := (grid.lons[0] + grid.lons[len(grid.lons)-1]) / 2
+		lon = middle + math.Remainder(lon-middle, 360)
+	}
+	i0, i1, fi := bracket(grid.lats, lat, false)
+	j0, j1, fj := bracket(grid.lons, lon, grid.globalLons)
+
+	blend := func(c [][]float64) float64 {
+		low := c[i0][j0]*(1-fj) + c[i0][j1]*fj
+		high := c[i1][j0]*(1-fj) + c[i1][j1]*fj
+		return low*(1-fi) + high*fi
+	}
+	return blend(grid.u), blend(grid.v)
+}
+
+// AirDistance returns the still-air distance equivalent to flying the great
+// circle arc from one point to another at the given true airspeed, i.e., the
+// ground distance scaled by airspeed over groundspeed along the way. It is
+// not ok when the wind is strong enough to stop the aircraft. Between
+// antipodal points it takes the arc sphere.ArcNormal does.
+func (grid *Grid) AirDistance(from, to *sphere.NVector, sphereRadius, trueAirspeed float64) (distance float64, ok bool) {
+	groundDistance := from.AngleBetween(to) * sphereRadius
+	if groundDistance == 0 {
+		return 0, true
+	}
+	normal := from.ArcNormal(to)
+
+	samples := int(math.Ceil(groundDistance / SAMPLE_SPACING_KM))
+	step := groundDistance / float64(samples)
+	for i := 0; i < samples; i++ {
+		p := from.Interpolate(to, (float64(i)+0.5)/float64(samples))
+		lat, lon := p.ToLatLonDegrees()
+		u, v := grid.At(lat, lon)
+
+		east, north := p.EastNorth()
+		w := east.ScaleBy(u).Add(north.ScaleBy(v))
+		track := p.TrackDirection(normal)
+		along := w.DotProduct(track)
+		cross := w.Subtract(track.ScaleBy(along)).Magnitude()
+
+		if cross >= trueAirspeed {
+			return 0, false
+		}
+		groundSpeed := math.Sqrt(trueAirspeed*trueAirspeed-cross*cross) + along
+		if groundSpeed <= 0 {
+			return 0, false
+		}
+		distance += step * trueAirspeed / groundSpeed
+	}
+
+	return distance, true
+}
@@ -0,0 +1,115 @@
+package wind
+
+import (
+	"fmt"
+	"math"
+	"sphere"
+	"strings"
+	"testing"
+)
+
+const (
+	earthRadiusKm = 6370.0
+	airspeedKmh   = 800.0
+	floatEpsilon  = 0.000000001
+)
+
+// A global 30 degree grid with the same wind everywhere.
+func uniformGrid(t *testing.T, u, v float64) *Grid {
+	var b strings.Builder
+	for lat := -90; lat <= 90; lat += 30 {
+		for lon := -180; lon < 180; lon += 30 {
+			fmt.Fprintf(&b, "%d,%d,%g,%g\n", lat, lon, u, v)
+		}
+	}
+	grid, err := ReadGrid(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("couldn't read grid: %s", err)
+	}
+	return grid
+}
+
+func TestCalm(t *testing.T) {
+	grid := uniformGrid(t, 0, 0)
+	from := sphere.NewNVectorFromLatLongDeg(33.94, -118.40)
+	to := sphere.NewNVectorFromLatLongDeg(36.12, -86.67)
+	ground := from.AngleBetween(to) * earthRadiusKm
+	air, ok := grid.AirDistance(from, to, earthRadiusKm, airspeedKmh)
+	if !ok || math.Abs(air-ground) > 0.001 {
+		t.Errorf("calm air distance %f differs from ground distance %f", air, ground)
+	}
+}
+
+func TestTailAndHeadwind(t *testing.T) {
+	grid := uniformGrid(t, 100, 0)
+	west := sphere.NewNVectorFromLatLongDeg(0, -10)
+	east := sphere.NewNVectorFromLatLongDeg(0, 10)
+	ground := west.AngleBetween(east) * earthRadiusKm
+
+	eastbound, ok1 := grid.AirDistance(west, east, earthRadiusKm, airspeedKmh)
+	westbound, ok2 := grid.AirDistance(east, west, earthRadiusKm, airspeedKmh)
+	if !ok1 || !ok2 {
+		t.Fatalf("wind should not stop the aircraft")
+	}
+	if math.Abs(eastbound-ground*800/900) > 0.01 {
+		t.Errorf("eastbound air distance %f, expected %f", eastbound, ground*800/900)
+	}
+	if math.Abs(westbound-ground*800/700) > 0.01 {
+		t.Errorf("westbound air distance %f, expected %f", westbound, ground*800/700)
+	}
+
+	if _, ok := uniformGrid(t, 900, 0).AirDistance(east, west, earthRadiusKm, airspeedKmh); ok {
+		t.Errorf("headwind beyond airspeed should not be flyable")
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	grid, err := ReadGrid(strings.NewReader("0,0,0,0\n0,10,10,0\n10,0,0,20\n10,10,10,20\n"))
+	if err != nil {
+		t.Fatalf("couldn't read grid: %s", err)
+	}
+	u, v := grid.At(5, 5)
+	if math.Abs(u-5) > floatEpsilon || math.Abs(v-10) > floatEpsilon {
+		t.Errorf("wind at grid center is (%f, %f)", u, v)
+	}
+
+	if _, err := ReadGrid(strings.NewReader("0,0,0,0\n0,10,10,0\n10,0,0,20\n")); err == nil {
+		t.Errorf("incomplete grid should be rejected")
+	}
+}
+
+// TestRegionalGrid checks that a grid covering part of the globe is not
+// wrapped round, so that beyond its edges the wind at the nearest edge is
+// taken, including across the antimeridian.
+func TestRegionalGrid(t *testing.T) {
+	grid, err := ReadGrid(strings.NewReader("0,0,0,0\n0,10,10,0\n10,0,0,20\n10,10,10,20\n"))
+	if err != nil {
+		t.Fatalf("couldn't read grid: %s", err)
+	}
+	for _, lon := range []float64{-5, 355, 0} {
+		if u, v := grid.At(5, lon); math.Abs(u) > floatEpsilon || math.Abs(v-10) > floatEpsilon {
+			t.Errorf("wind west of the grid at longitude %f is (%f, %f) rather than (0, 10)", lon, u, v)
+		}
+	}
+
+	pacific, err := ReadGrid(strings.NewReader("0,170,0,0\n0,190,20,0\n10,170,0,0\n10,190,20,0\n"))
+	if err != nil {
+		t.Fatalf("couldn't read grid: %s", err)
+	}
+	if u, _ := pacific.At(5, -175); math.Abs(u-15) > floatEpsilon {
+		t.Errorf("wind across the antimeridian is %f rather than 15", u)
+	}
+}
+
+func TestAntipodal(t *testing.T) {
+	from := sphere.NewNVectorFromLatLongDeg(0, 0)
+	to := sphere.NewNVectorFromLatLongDeg(0, 180)
+	ground := math.Pi * earthRadiusKm
+	air, ok := uniformGrid(t, 0, 0).AirDistance(from, to, earthRadiusKm, airspeedKmh)
+	if !ok || math.IsNaN(air) || math.Abs(air-ground) > 0.001 {
+		t.Errorf("calm air distance %f (%t) between antipodes differs from ground distance %f", air, ok, ground)
+	}
+	if air, ok := uniformGrid(t, 100, 0).AirDistance(from, to, earthRadiusKm, airspeedKmh); !ok || math.IsNaN(air) {
+		t.Errorf("air distance between antipodes through wind is %f (%t)", air, ok)
+	}
+}